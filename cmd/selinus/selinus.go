@@ -1,15 +1,355 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/format"
+	"github.com/cevatbarisyilmaz/selinus/lexer"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+	"github.com/cevatbarisyilmaz/selinus/repl"
 	"github.com/cevatbarisyilmaz/selinus/runner"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+const usage = `Usage: selinus <command> [flags] [arguments]
+
+Commands:
+  run [flags] file|- [arguments]  runs a program with the given arguments, "selinus file" is short for it
+  build file [output]             compiles a program to bytecode
+  check [flags] file|-            compiles a program without running it and reports suspicious code
+  fmt [flags] [paths]             formats the programs at the paths, or the standard input
+  test [flags] [paths]            runs the programs ending in _test.selinus found at the paths
+  repl [flags]                    starts an interactive session
+  tokens [flags] file|-           prints the tokens of a program
+  ast [flags] file|-              prints the syntax tree of a program
+
+A program is read from the standard input when its file is -, and the commands that accept flags take it as code with
+-e instead of a file. Run "selinus <command> -h" for the flags of a command.
+
+Exit codes: 0 on success, 1 when the program raised an exception, a test failed or check reported warnings, 2 when the
+program could not be compiled and 3 for the other errors.
+`
+
+var commands = map[string]func(args []string) int{
+	"run":    run,
+	"build":  build,
+	"check":  check,
+	"fmt":    formatPrograms,
+	"test":   test,
+	"repl":   runREPL,
+	"tokens": tokens,
+	"ast":    ast,
+}
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Please provide a target file")
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(runner.ExitError)
+	}
+	name := os.Args[1]
+	if command, ok := commands[name]; ok {
+		os.Exit(command(os.Args[2:]))
+	}
+	switch name {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
 		return
 	}
-	os.Exit(runner.Run(os.Args[1], ""))
+	// Anything else is the file, or the flags, of a program to run.
+	os.Exit(run(os.Args[1:]))
+}
+
+// newFlagSet returns the flag set of a command, whose usage message lists the flags under the synopsis.
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: selinus %s %s\n", name, synopsis)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// runFlags adds the flags that configure how programs run to the flag set and returns a function that turns their
+// values into options once they are parsed. The options import the os module, which the command line always gives to
+// programs.
+func runFlags(flags *flag.FlagSet) func() ([]runner.Option, error) {
+	backend := flags.String("backend", "tree", "the backend that runs the program, tree or vm")
+	timeout := flags.Duration("timeout", 0, "stop the program with an exception after the given duration")
+	deterministic := flags.Bool("deterministic", false, "run the tasks of the program one at a time in an order picked with a random seed, which is printed when the program fails")
+	seed := flags.Int64("seed", 0, "replay a deterministic run with the given seed")
+	return func() ([]runner.Option, error) {
+		opts := []runner.Option{runner.WithOS()}
+		switch *backend {
+		case "tree":
+			opts = append(opts, runner.WithBackend(runner.TreeWalker))
+		case "vm":
+			opts = append(opts, runner.WithBackend(runner.VirtualMachine))
+		default:
+			return nil, fmt.Errorf("unknown backend %s, expected tree or vm", *backend)
+		}
+		if *timeout > 0 {
+			opts = append(opts, runner.WithTimeout(*timeout))
+		}
+		seeded := false
+		flags.Visit(func(f *flag.Flag) {
+			seeded = seeded || f.Name == "seed"
+		})
+		if seeded {
+			opts = append(opts, runner.WithSeed(*seed))
+		} else if *deterministic {
+			opts = append(opts, runner.WithSeed(time.Now().UnixNano()))
+		}
+		return opts, nil
+	}
+}
+
+// source returns the path and the content of the program given with -e or by the first argument, followed by the
+// arguments after it. The content is empty when the program is to be read from the path.
+func source(flags *flag.FlagSet, inline string) (string, string, []string, error) {
+	args := flags.Args()
+	if inline != "" {
+		return "-e", inline + "\n", args, nil
+	}
+	if len(args) == 0 {
+		return "", "", nil, fmt.Errorf("expected a file, - or -e")
+	}
+	if args[0] != "-" {
+		return args[0], "", args[1:], nil
+	}
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", "", nil, err
+	}
+	// The new line keeps the content of an empty input from being taken as a path.
+	return "stdin", string(content) + "\n", args[1:], nil
+}
+
+// fail prints the error of a command to the standard error and returns ExitError.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return runner.ExitError
+}
+
+// flagError returns the exit code for an error of flag.FlagSet.Parse, which has already printed it. Asking for the
+// usage with -h is not a failure.
+func flagError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return runner.ExitSuccess
+	}
+	return runner.ExitError
+}
+
+func run(args []string) int {
+	flags := newFlagSet("run", "[flags] file|- [arguments]")
+	inline := flags.String("e", "", "run the given code instead of a file")
+	options := runFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	opts, err := options()
+	if err != nil {
+		return fail(err)
+	}
+	filePath, fileContent, programArgs, err := source(flags, *inline)
+	if err != nil {
+		return fail(err)
+	}
+	return runner.Run(filePath, fileContent, append(opts, runner.WithArgs(programArgs))...)
+}
+
+func build(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "Usage: selinus build file [output]")
+		return runner.ExitError
+	}
+	target := args[0]
+	output := strings.TrimSuffix(target, filepath.Ext(target)) + vm.Extension
+	if len(args) > 1 {
+		output = args[1]
+	}
+	return runner.Build(target, "", output, runner.WithOS())
+}
+
+func check(args []string) int {
+	flags := newFlagSet("check", "[flags] file|-")
+	inline := flags.String("e", "", "check the given code instead of a file")
+	asJSON := flags.Bool("json", false, "print the error or the warnings as a JSON array")
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	filePath, fileContent, _, err := source(flags, *inline)
+	if err != nil {
+		return fail(err)
+	}
+	return runner.Check(filePath, fileContent, os.Stdout, *asJSON, runner.WithOS())
+}
+
+// formatPrograms formats the programs at the paths, the files ending in .selinus are formatted in directories. The
+// formatted programs are printed unless -l, -w or -d is given. A program that does not parse is reported and left as
+// it is.
+func formatPrograms(args []string) int {
+	flags := newFlagSet("fmt", "[flags] [paths]")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	write := flags.Bool("w", false, "write the formatted programs to their files")
+	diff := flags.Bool("d", false, "print the changes formatting makes as diffs")
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	if flags.NArg() == 0 {
+		if *list || *write {
+			return fail(errors.New("-l and -w can not be used with the standard input"))
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fail(err)
+		}
+		return formatProgram("<standard input>", string(src), false, false, *diff)
+	}
+	code := runner.ExitSuccess
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || file != path && filepath.Ext(file) != ".selinus" {
+				return nil
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if c := formatProgram(file, string(src), *list, *write, *diff); c != runner.ExitSuccess {
+				code = c
+			}
+			return nil
+		})
+		if err != nil {
+			code = fail(err)
+		}
+	}
+	return code
+}
+
+// formatProgram formats the source code of the program at path as formatPrograms describes.
+func formatProgram(path, src string, list, write, diff bool) int {
+	formatted, err := format.Source(path, src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return runner.ExitCompileError
+	}
+	if list && formatted != src {
+		fmt.Println(path)
+	}
+	if write && formatted != src {
+		if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			return fail(err)
+		}
+	}
+	if diff {
+		fmt.Print(format.Diff(path, src, formatted))
+	}
+	if !list && !write && !diff {
+		fmt.Print(formatted)
+	}
+	return runner.ExitSuccess
+}
+
+func test(args []string) int {
+	flags := newFlagSet("test", "[flags] [paths]")
+	options := runFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	opts, err := options()
+	if err != nil {
+		return fail(err)
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	return runner.Test(paths, os.Stdout, opts...)
+}
+
+// runREPL runs a read-eval-print loop on the standard streams. The inputs are stored in the .selinus_history file in the
+// home directory of the user unless another file is given.
+func runREPL(args []string) int {
+	flags := newFlagSet("repl", "[flags]")
+	historyPath := flags.String("history", "", "the file that keeps the inputs, .selinus_history in the home directory by default")
+	options := runFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	opts, err := options()
+	if err != nil {
+		return fail(err)
+	}
+	if *historyPath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			*historyPath = filepath.Join(home, ".selinus_history")
+		}
+	}
+	r, err := repl.New(os.Stdin, os.Stdout, *historyPath, opts...)
+	if err == nil {
+		err = r.Run()
+	}
+	var exit *runner.Exit
+	if errors.As(err, &exit) {
+		return exit.Code
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return runner.ExitSuccess
+}
+
+func tokens(args []string) int {
+	flags := newFlagSet("tokens", "[flags] file|-")
+	inline := flags.String("e", "", "print the tokens of the given code instead of a file")
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	filePath, fileContent, _, err := source(flags, *inline)
+	if err != nil {
+		return fail(err)
+	}
+	lexTokens, err := runner.Lex(filePath, fileContent)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return runner.ExitCompileError
+	}
+	for _, token := range lexTokens {
+		value := token.Value
+		if token.TokenType != lexer.Indent && token.TokenType != lexer.Dedent {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Printf("%d:%d\t%s\t%s\n", token.Line, token.Position, token.TokenType, value)
+	}
+	return runner.ExitSuccess
+}
+
+func ast(args []string) int {
+	flags := newFlagSet("ast", "[flags] file|-")
+	inline := flags.String("e", "", "print the syntax tree of the given code instead of a file")
+	if err := flags.Parse(args); err != nil {
+		return flagError(err)
+	}
+	filePath, fileContent, _, err := source(flags, *inline)
+	if err != nil {
+		return fail(err)
+	}
+	root, err := runner.Parse(filePath, fileContent)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return runner.ExitCompileError
+	}
+	fmt.Print(parser.Dump(root))
+	return runner.ExitSuccess
 }
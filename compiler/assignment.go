@@ -0,0 +1,285 @@
+package compiler
+
+import (
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// assignmentState holds the declarations that are definitely assigned at a point of the program.
+type assignmentState map[*core.Pointer]bool
+
+func (state assignmentState) copy() assignmentState {
+	c := make(assignmentState, len(state))
+	for declaration := range state {
+		c[declaration] = true
+	}
+	return c
+}
+
+func (state assignmentState) intersect(other assignmentState) assignmentState {
+	c := make(assignmentState)
+	for declaration := range state {
+		if other[declaration] {
+			c[declaration] = true
+		}
+	}
+	return c
+}
+
+// assignmentChecker rejects reads of variables that might not be assigned yet. Variables declared by an enclosing
+// function are checked at runtime instead when they are not assigned before the function is declared, since the
+// function might be called after they are.
+type assignmentChecker struct {
+	owners map[*core.Pointer]int
+	depth  int
+}
+
+func checkAssignments(root core.Node) error {
+	checker := &assignmentChecker{owners: make(map[*core.Pointer]int)}
+	_, _, err := checker.block(root, make(assignmentState))
+	return err
+}
+
+func (checker *assignmentChecker) declare(declaration *core.Pointer, assigned assignmentState) {
+	if declaration == nil {
+		return
+	}
+	checker.owners[declaration] = checker.depth
+	delete(assigned, declaration)
+}
+
+func (checker *assignmentChecker) read(node core.Node, variable *VariableNode, assigned assignmentState) error {
+	declaration := variable.declaration
+	if declaration == nil {
+		variable.unproven = true
+		return nil
+	}
+	if assigned[declaration] {
+		return nil
+	}
+	owner, tracked := checker.owners[declaration]
+	if tracked && owner == checker.depth {
+		return errors.New(variable.name + " might be used before it is assigned " + node.Position())
+	}
+	if declaration.Variable == nil {
+		variable.unproven = true
+	}
+	return nil
+}
+
+// block walks the statements of a block and reports whether it returns on every path.
+func (checker *assignmentChecker) block(node core.Node, assigned assignmentState) (assignmentState, bool, error) {
+	for ; node != nil; node = node.Next() {
+		var returned bool
+		var err error
+		assigned, returned, err = checker.walk(node, assigned)
+		if err != nil || returned {
+			return assigned, returned, err
+		}
+	}
+	return assigned, false, nil
+}
+
+func (checker *assignmentChecker) walk(node core.Node, assigned assignmentState) (assignmentState, bool, error) {
+	if node == nil {
+		return assigned, false, nil
+	}
+	var err error
+	switch root := node.Root().(type) {
+	case *VariableNode:
+		err = checker.read(node, root, assigned)
+	case *DeclarationNode:
+		checker.declare(root.declaration, assigned)
+	case *SetNode:
+		assigned, _, err = checker.walk(root.rightSide, assigned)
+		if err != nil {
+			break
+		}
+		switch left := root.leftSide.Root().(type) {
+		case *DeclarationNode:
+			checker.declare(left.declaration, assigned)
+			assigned[left.declaration] = true
+		case *VariableNode:
+			if left.declaration != nil {
+				assigned[left.declaration] = true
+			}
+		default:
+			assigned, _, err = checker.walk(root.leftSide, assigned)
+		}
+	case *ConditionNode:
+		assigned, _, err = checker.walk(root.condition, assigned)
+		if err != nil {
+			break
+		}
+		then, thenReturned, err := checker.block(root.root, assigned.copy())
+		if err != nil {
+			return assigned, false, err
+		}
+		otherwise, otherwiseReturned, err := checker.block(root.otherwise, assigned.copy())
+		if err != nil {
+			return assigned, false, err
+		}
+		switch {
+		case thenReturned && otherwiseReturned:
+			return assigned, true, nil
+		case thenReturned:
+			return otherwise, false, nil
+		case otherwiseReturned:
+			return then, false, nil
+		}
+		return then.intersect(otherwise), false, nil
+	case *ToLoopNode:
+		assigned, _, err = checker.walk(root.from, assigned)
+		if err != nil {
+			break
+		}
+		assigned, _, err = checker.walk(root.to, assigned)
+		if err != nil {
+			break
+		}
+		body := assigned.copy()
+		checker.declare(root.declaration, body)
+		if root.declaration != nil {
+			body[root.declaration] = true
+		}
+		_, _, err = checker.block(root.root, body)
+	case *ConditionLoopNode:
+		assigned, _, err = checker.walk(root.condition, assigned)
+		if err != nil {
+			break
+		}
+		_, _, err = checker.block(root.root, assigned.copy())
+	case *FunctionNode:
+		checker.declare(root.declaration, assigned)
+		if root.declaration != nil {
+			assigned[root.declaration] = true
+		}
+		body := assigned.copy()
+		checker.depth++
+		for _, declaration := range root.parameterDeclarations {
+			checker.declare(declaration, body)
+			body[declaration] = true
+		}
+		_, _, err = checker.block(root.entryNode, body)
+		checker.depth--
+	case *SelectNode:
+		return checker.selection(root, assigned)
+	case *SpawnNode:
+		assigned, _, err = checker.walk(root.call, assigned)
+	case *ChannelNode:
+		assigned, _, err = checker.walk(root.size, assigned)
+	case *SendNode:
+		assigned, err = checker.walkAll([]core.Node{root.channel, root.value}, assigned)
+	case *ReceiveNode:
+		assigned, _, err = checker.walk(root.channel, assigned)
+	case *CloseNode:
+		assigned, _, err = checker.walk(root.channel, assigned)
+	case *ReturnNode:
+		assigned, _, err = checker.walk(root.node, assigned)
+		return assigned, true, err
+	case *ResultNode:
+		assigned, _, err = checker.walk(root.node, assigned)
+	case *OrNode:
+		assigned, _, err = checker.walk(root.left, assigned)
+		if err != nil {
+			break
+		}
+		_, _, err = checker.walk(root.right, assigned.copy())
+	case *AndNode:
+		assigned, _, err = checker.walk(root.left, assigned)
+		if err != nil {
+			break
+		}
+		_, _, err = checker.walk(root.right, assigned.copy())
+	case *ConversionNode:
+		assigned, _, err = checker.walk(root.node, assigned)
+	case *CsvNode:
+		assigned, err = checker.walkAll(root.children, assigned)
+	case *FunctionCallNode:
+		assigned, err = checker.walkAll(root.parameters, assigned)
+	case *SummationNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *SubtractionNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *MultiplicationNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *DivisionNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *BigIntegerArithmeticNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *ConcatenationNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *EqualityNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *InequalityNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *GreaterNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *GreaterOrEqualNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *LessNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	case *LessOrEqualNode:
+		assigned, err = checker.walkAll([]core.Node{root.left, root.right}, assigned)
+	}
+	return assigned, false, err
+}
+
+// selection walks the operands of the cases of a select, which are all evaluated, and then their bodies, of which one
+// runs.
+func (checker *assignmentChecker) selection(node *SelectNode, assigned assignmentState) (assignmentState, bool, error) {
+	var err error
+	for _, c := range node.cases {
+		root := c.Root().(*SelectCaseNode)
+		assigned, err = checker.walkAll([]core.Node{root.channel, root.value}, assigned)
+		if err != nil {
+			return assigned, false, err
+		}
+	}
+	var result assignmentState
+	merge := func(branch assignmentState, returned bool) {
+		if returned {
+			return
+		}
+		if result == nil {
+			result = branch
+		} else {
+			result = result.intersect(branch)
+		}
+	}
+	for _, c := range node.cases {
+		root := c.Root().(*SelectCaseNode)
+		body := assigned.copy()
+		checker.declare(root.declaration, body)
+		if root.declaration != nil {
+			body[root.declaration] = true
+		}
+		branch, returned, err := checker.block(root.root, body)
+		if err != nil {
+			return assigned, false, err
+		}
+		merge(branch, returned)
+	}
+	if node.hasOtherwise {
+		branch, returned, err := checker.block(node.otherwise, assigned.copy())
+		if err != nil {
+			return assigned, false, err
+		}
+		merge(branch, returned)
+	}
+	if result == nil {
+		return assigned, true, nil
+	}
+	return result, false, nil
+}
+
+func (checker *assignmentChecker) walkAll(nodes []core.Node, assigned assignmentState) (assignmentState, error) {
+	var err error
+	for _, node := range nodes {
+		assigned, _, err = checker.walk(node, assigned)
+		if err != nil {
+			return assigned, err
+		}
+	}
+	return assigned, nil
+}
@@ -0,0 +1,69 @@
+package builtin
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
+)
+
+var BigIntegerType = &core.Type{Name: "BigInteger", Parent: core.VariableType, Methods: map[string]core.Function{}, Converters: map[*core.Type]core.Function{
+	StringType: &BigIntegerToStringConverterFunction{},
+}, Scope: core.NewScope()}
+
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (*BigInteger) GetType() *core.Type {
+	return BigIntegerType
+}
+
+func NewBigIntegerPointer(value *big.Int) *core.Pointer {
+	return &core.Pointer{
+		Typ:      BigIntegerType,
+		Variable: core.NewVariable(&BigInteger{Value: value}),
+	}
+}
+
+var BigIntegerToStringConverterFunctionType = &core.Type{Parent: FunctionType, Name: "convertBigIntegerToString", Generic: true, Generics: []*core.Type{StringType}}
+
+type BigIntegerToStringConverterFunction struct{}
+
+func (bigIntegerToStringConverterFunction *BigIntegerToStringConverterFunction) Execute(scope *core.Scope) *core.Return {
+	getResult := scope.Get(core.Self)
+	if getResult.ReturnType != core.NOTHING {
+		return getResult
+	}
+	return &core.Return{
+		ReturnType: core.NOTHING,
+		Pointer:    NewStringPointer(getResult.Pointer.Variable.VariableInterface.(*BigInteger).Value.String()),
+	}
+}
+
+func (bigIntegerToStringConverterFunction *BigIntegerToStringConverterFunction) GetType() *core.Type {
+	return BigIntegerToStringConverterFunctionType
+}
+
+func (bigIntegerToStringConverterFunction *BigIntegerToStringConverterFunction) GetParameters() []*core.Parameter {
+	return nil
+}
+
+func (bigIntegerToStringConverterFunction *BigIntegerToStringConverterFunction) GetReturnType() *core.Type {
+	return StringType
+}
+
+func (bigIntegerToStringConverterFunction *BigIntegerToStringConverterFunction) GetScope() *core.Scope {
+	return scope
+}
+
+func init() {
+	BigIntegerType.Converters[IntegerType] = newConverterFunction("convertBigIntegerToInteger", IntegerType, func(value core.VariableInterface) *core.Return {
+		i := value.(*BigInteger).Value
+		if !i.IsInt64() {
+			return core.NewExceptionReturn("big integer " + i.String() + " does not fit in an integer")
+		}
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewIntegerPointer(i.Int64())}
+	})
+	BigIntegerType.Converters[BooleanType] = newConverterFunction("convertBigIntegerToBoolean", BooleanType, func(value core.VariableInterface) *core.Return {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewBooleanPointer(value.(*BigInteger).Value.Sign() != 0)}
+	})
+}
@@ -18,3 +18,18 @@ func NewBooleanPointer(value bool) *core.Pointer {
 func (Boolean) GetType() *core.Type {
 	return BooleanType
 }
+
+func init() {
+	BooleanType.Converters[StringType] = newConverterFunction("convertBooleanToString", StringType, func(value core.VariableInterface) *core.Return {
+		if value.(*Boolean).Value {
+			return &core.Return{ReturnType: core.NOTHING, Pointer: NewStringPointer("true")}
+		}
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewStringPointer("false")}
+	})
+	BooleanType.Converters[IntegerType] = newConverterFunction("convertBooleanToInteger", IntegerType, func(value core.VariableInterface) *core.Return {
+		if value.(*Boolean).Value {
+			return &core.Return{ReturnType: core.NOTHING, Pointer: NewIntegerPointer(1)}
+		}
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewIntegerPointer(0)}
+	})
+}
@@ -5,10 +5,17 @@ import (
 )
 
 var Block = core.NewScopeBlock(map[string]*core.Pointer{
-	"int":    {Typ: core.TypeType, Variable: core.TypeToVariable(IntegerType)},
-	"bool":   {Typ: core.TypeType, Variable: core.TypeToVariable(BooleanType)},
-	"string": {Typ: core.TypeType, Variable: core.TypeToVariable(StringType)},
-	"func":   {Typ: core.TypeType, Variable: core.TypeToVariable(FunctionType)},
+	"int":    {Typ: core.TypeType, Variable: core.TypeToVariable(IntegerType), Immutable: true},
+	"bigint": {Typ: core.TypeType, Variable: core.TypeToVariable(BigIntegerType), Immutable: true},
+	"bool":   {Typ: core.TypeType, Variable: core.TypeToVariable(BooleanType), Immutable: true},
+	"string": {Typ: core.TypeType, Variable: core.TypeToVariable(StringType), Immutable: true},
+	"func":   {Typ: core.TypeType, Variable: core.TypeToVariable(FunctionType), Immutable: true},
+	"chan":   {Typ: core.TypeType, Variable: core.TypeToVariable(ChannelType), Immutable: true},
+
+	"channel": ChannelFunction,
+	"send":    SendFunction,
+	"receive": ReceiveFunction,
+	"close":   CloseFunction,
 })
 
 var scope = core.NewScope()
@@ -0,0 +1,373 @@
+package builtin
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+var ChannelType = &core.Type{Name: "Channel", Parent: core.VariableType, Methods: map[string]core.Function{}, Converters: map[*core.Type]core.Function{}, Scope: core.NewScope()}
+
+// NewChannelSubType returns the type of the channels that hold values of the element type.
+func NewChannelSubType(element *core.Type) *core.Type {
+	return &core.Type{
+		Name:     "Channel",
+		Parent:   ChannelType,
+		Methods:  nil,
+		Generic:  true,
+		Generics: []*core.Type{element},
+	}
+}
+
+// ChannelFunction, SendFunction, ReceiveFunction and CloseFunction are the declarations of channel, send, receive and
+// close. They hold no function, the compiler turns calls to them into the channel operations below.
+var (
+	ChannelFunction = &core.Pointer{Typ: FunctionType, Immutable: true}
+	SendFunction    = &core.Pointer{Typ: FunctionType, Immutable: true}
+	ReceiveFunction = &core.Pointer{Typ: FunctionType, Immutable: true}
+	CloseFunction   = &core.Pointer{Typ: FunctionType, Immutable: true}
+)
+
+// IsIntrinsic reports whether the declaration is one of the channel operations that are compiled rather than called.
+func IsIntrinsic(declaration *core.Pointer) bool {
+	return declaration == ChannelFunction || declaration == SendFunction || declaration == ReceiveFunction || declaration == CloseFunction
+}
+
+// ElementOf returns the type of the values the channels of type typ hold, which is var when it is not known.
+func ElementOf(typ *core.Type) *core.Type {
+	if typ != nil && typ.Generic && typ.Parent == ChannelType {
+		return typ.Generics[0]
+	}
+	return core.VariableType
+}
+
+// Channel passes values between the tasks of a program. A send appends an offer to the channel. The first offers, as
+// many as the channel buffers, are in its buffer and the sends that made them are done. The sends that made the other
+// offers wait until their offer is received. A select that has to wait registers its cases on their channels, so that
+// the tasks using the channels can complete one of them.
+//
+// The operations lock the channels of the program with core.Thread.Lock. A task that can not proceed waits for another
+// task to change a channel with core.Thread.Wait, and a task that changed one tells the waiting tasks with
+// core.Thread.Notify. Waiting operations give up with an exception once the context of the thread is done or every
+// task of the program waits for another one.
+type Channel struct {
+	Element *core.Type
+	// size is the number of values the channel buffers.
+	size   int
+	closed bool
+	// offers are the values sent on the channel that have not been received yet.
+	offers []*offer
+	// receivers is the number of receive operations waiting for a value from the channel.
+	receivers int
+	// selections are the cases of the waiting selects that use the channel.
+	selections []*selection
+}
+
+type offer struct {
+	value    *core.Pointer
+	received bool
+}
+
+// waiter is a select waiting for one of its cases to proceed. The task that performs a case for it sets done, chosen
+// and, for a receive, received.
+type waiter struct {
+	done     bool
+	chosen   int
+	received *core.Pointer
+}
+
+// selection is a case of a waiting select. Index is the index of the case and value is the value to send, or nil for a
+// receive.
+type selection struct {
+	waiter *waiter
+	index  int
+	value  *core.Pointer
+}
+
+func (*Channel) GetType() *core.Type {
+	return ChannelType
+}
+
+// NewChannelPointer returns a channel of values of the element type that buffers up to size values.
+func NewChannelPointer(element *core.Type, size int64) (*core.Pointer, *core.Return) {
+	if size < 0 {
+		return nil, core.NewExceptionReturn(fmt.Sprintf("negative channel size %d", size))
+	}
+	return &core.Pointer{
+		Typ:      NewChannelSubType(element),
+		Variable: core.NewVariable(&Channel{Element: element, size: int(size)}),
+	}, nil
+}
+
+// ChannelOf returns the channel the pointer holds, or an exception when it does not hold one.
+func ChannelOf(pointer *core.Pointer) (*Channel, *core.Return) {
+	variable, exception := variableOf(pointer, ChannelType)
+	if exception != nil {
+		return nil, exception
+	}
+	channel, ok := variable.VariableInterface.(*Channel)
+	if !ok {
+		return nil, typeMismatch(variable, ChannelType)
+	}
+	return channel, nil
+}
+
+// check returns the value as it is stored in the channel or an exception when the channel can not hold it. Generic
+// element types are checked by the compiler, only their base type is checked here.
+func (channel *Channel) check(value *core.Pointer) (*core.Pointer, *core.Return) {
+	if value == nil || value.Variable == nil {
+		return nil, core.NewExceptionReturn("expected " + channel.Element.Name + " but got nothing")
+	}
+	element := channel.Element
+	for element.Generic {
+		element = element.Parent
+	}
+	if !value.Variable.GetType().IsCompatible(element) {
+		return nil, core.NewExceptionReturn("channel of " + channel.Element.Name + " can not hold " + value.Variable.GetType().Name)
+	}
+	return &core.Pointer{Typ: channel.Element, Variable: value.Variable}, nil
+}
+
+// buffered reports whether the offer is in the buffer of the channel.
+func (channel *Channel) buffered(o *offer) bool {
+	for i := 0; i < len(channel.offers) && i < channel.size; i++ {
+		if channel.offers[i] == o {
+			return true
+		}
+	}
+	return false
+}
+
+func (channel *Channel) withdraw(o *offer) {
+	for i, other := range channel.offers {
+		if other == o {
+			channel.offers = append(channel.offers[:i], channel.offers[i+1:]...)
+			return
+		}
+	}
+}
+
+// take removes the first offer of the channel and returns its value.
+func (channel *Channel) take() *core.Pointer {
+	o := channel.offers[0]
+	channel.offers = channel.offers[1:]
+	o.received = true
+	return o.value
+}
+
+// selection returns a case of a waiting select that sends on the channel when send is set and receives from it
+// otherwise, or nil when there is none. A select receiving from the channel only takes a value that is not preceded by
+// offers.
+func (channel *Channel) selection(send bool) *selection {
+	if !send && len(channel.offers) > 0 {
+		return nil
+	}
+	for _, s := range channel.selections {
+		if !s.waiter.done && (s.value != nil) == send {
+			return s
+		}
+	}
+	return nil
+}
+
+// perform performs the case of the waiting select and returns the value it sends.
+func (s *selection) perform(received *core.Pointer) *core.Pointer {
+	s.waiter.done = true
+	s.waiter.chosen = s.index
+	s.waiter.received = received
+	return s.value
+}
+
+// Send sends the value on the channel, waiting for a receiver or for room in the buffer.
+func Send(thread *core.Thread, pointer *core.Pointer, value *core.Pointer) *core.Return {
+	channel, exception := ChannelOf(pointer)
+	if exception != nil {
+		return exception
+	}
+	value, exception = channel.check(value)
+	if exception != nil {
+		return exception
+	}
+	thread.Lock()
+	defer thread.Unlock()
+	if channel.closed {
+		return core.NewExceptionReturn("send on closed channel")
+	}
+	if s := channel.selection(false); s != nil {
+		s.perform(value)
+		thread.Notify()
+		return nil
+	}
+	o := &offer{value: value}
+	channel.offers = append(channel.offers, o)
+	thread.Notify()
+	for !o.received && !channel.buffered(o) {
+		if channel.closed {
+			channel.withdraw(o)
+			return core.NewExceptionReturn("send on closed channel")
+		}
+		if exception := thread.Wait(); exception != nil {
+			channel.withdraw(o)
+			return exception
+		}
+	}
+	return nil
+}
+
+// Receive receives a value from the channel, waiting for a sender when the buffer is empty. Receiving from a closed
+// channel whose buffer is empty raises an exception.
+func Receive(thread *core.Thread, pointer *core.Pointer) (*core.Pointer, *core.Return) {
+	channel, exception := ChannelOf(pointer)
+	if exception != nil {
+		return nil, exception
+	}
+	thread.Lock()
+	defer thread.Unlock()
+	channel.receivers++
+	defer func() {
+		channel.receivers--
+	}()
+	thread.Notify()
+	for len(channel.offers) == 0 {
+		if s := channel.selection(true); s != nil {
+			value := s.perform(nil)
+			thread.Notify()
+			return value, nil
+		}
+		if channel.closed {
+			return nil, core.NewExceptionReturn("receive from closed channel")
+		}
+		if exception := thread.Wait(); exception != nil {
+			return nil, exception
+		}
+	}
+	value := channel.take()
+	thread.Notify()
+	return value, nil
+}
+
+// Close closes the channel, the values in its buffer can still be received.
+func Close(thread *core.Thread, pointer *core.Pointer) *core.Return {
+	channel, exception := ChannelOf(pointer)
+	if exception != nil {
+		return exception
+	}
+	thread.Lock()
+	defer thread.Unlock()
+	if channel.closed {
+		return core.NewExceptionReturn("close of closed channel")
+	}
+	channel.closed = true
+	thread.Notify()
+	return nil
+}
+
+// SelectCase is a channel operation Select can perform. Value is the value to send for send operations and nil for
+// receive operations.
+type SelectCase struct {
+	Channel *core.Pointer
+	Value   *core.Pointer
+}
+
+// ready reports whether a case of a select can proceed. A send can when it does not have to wait, because the buffer
+// of the channel has room or a receive or a select waits for a value from it.
+func (channel *Channel) ready(send bool) bool {
+	if channel.closed {
+		return true
+	}
+	if send {
+		return len(channel.offers) < channel.size+channel.receivers || channel.selection(false) != nil
+	}
+	return len(channel.offers) > 0 || channel.selection(true) != nil
+}
+
+// Select performs one of the operations that can proceed, chosen at random when several can, waiting until one can
+// unless otherwise is set. It returns the index of the operation and the value it received, or -1 when no operation
+// could proceed and otherwise is set.
+func Select(thread *core.Thread, cases []SelectCase, otherwise bool) (int, *core.Pointer, *core.Return) {
+	channels := make([]*Channel, len(cases))
+	values := make([]*core.Pointer, len(cases))
+	for i, c := range cases {
+		var exception *core.Return
+		channels[i], exception = ChannelOf(c.Channel)
+		if exception != nil {
+			return 0, nil, exception
+		}
+		if c.Value != nil {
+			values[i], exception = channels[i].check(c.Value)
+			if exception != nil {
+				return 0, nil, exception
+			}
+		}
+	}
+	thread.Lock()
+	defer thread.Unlock()
+	thread.Notify()
+	w := &waiter{}
+	for {
+		var ready []int
+		for i, channel := range channels {
+			if channel.ready(values[i] != nil) {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) > 0 {
+			chosen := ready[thread.Pick(len(ready))]
+			received, exception := channels[chosen].proceed(values[chosen])
+			if exception != nil {
+				return 0, nil, exception
+			}
+			thread.Notify()
+			return chosen, received, nil
+		}
+		if otherwise {
+			return -1, nil, nil
+		}
+		for i, channel := range channels {
+			channel.selections = append(channel.selections, &selection{waiter: w, index: i, value: values[i]})
+		}
+		exception := thread.Wait()
+		for _, channel := range channels {
+			channel.unregister(w)
+		}
+		if w.done {
+			// Another task performed a case, which it counts as done even when this task gave up meanwhile.
+			return w.chosen, w.received, nil
+		}
+		if exception != nil {
+			return 0, nil, exception
+		}
+	}
+}
+
+// proceed performs a case of a select that is ready, sending value when it is not nil and receiving otherwise.
+func (channel *Channel) proceed(value *core.Pointer) (*core.Pointer, *core.Return) {
+	if value != nil {
+		if channel.closed {
+			return nil, core.NewExceptionReturn("send on closed channel")
+		}
+		if len(channel.offers) < channel.size+channel.receivers {
+			channel.offers = append(channel.offers, &offer{value: value})
+		} else {
+			channel.selection(false).perform(value)
+		}
+		return nil, nil
+	}
+	if len(channel.offers) > 0 {
+		return channel.take(), nil
+	}
+	if s := channel.selection(true); s != nil {
+		return s.perform(nil), nil
+	}
+	return nil, core.NewExceptionReturn("receive from closed channel")
+}
+
+// unregister removes the cases of the waiting select from the channel.
+func (channel *Channel) unregister(w *waiter) {
+	selections := channel.selections[:0]
+	for _, s := range channel.selections {
+		if s.waiter != w {
+			selections = append(selections, s)
+		}
+	}
+	channel.selections = selections
+}
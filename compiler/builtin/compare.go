@@ -0,0 +1,172 @@
+package builtin
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
+	"strings"
+)
+
+// IsEquatable reports whether values of the given types can be compared with == and !=.
+func IsEquatable(left *core.Type, right *core.Type) bool {
+	if left == core.VariableType || right == core.VariableType {
+		return true
+	}
+	if isNumeric(left) && isNumeric(right) {
+		return true
+	}
+	if isSet(left) && isSet(right) {
+		return true
+	}
+	if left != right {
+		return false
+	}
+	switch left {
+	case StringType, BooleanType, core.TypeType:
+		return true
+	}
+	return left.Methods[core.EqualsMethod] != nil || left.Methods[core.CompareMethod] != nil
+}
+
+// IsOrdered reports whether values of the given types can be compared with <, <=, > and >=.
+func IsOrdered(left *core.Type, right *core.Type) bool {
+	if left == core.VariableType || right == core.VariableType {
+		return true
+	}
+	if isNumeric(left) && isNumeric(right) {
+		return true
+	}
+	if left != right {
+		return false
+	}
+	return left == StringType || left.Methods[core.CompareMethod] != nil
+}
+
+// Equals compares two values, using the equals or compare method of the type when there is one.
+func Equals(left *core.Variable, right *core.Variable) (bool, *core.Return) {
+	if left == nil || right == nil {
+		return false, core.NewExceptionReturn("cannot compare uninitialized values")
+	}
+	lt := left.GetType()
+	rt := right.GetType()
+	if isNumeric(lt) && isNumeric(rt) {
+		c, r := compareNumbers(left, right)
+		return c == 0, r
+	}
+	if lt != rt {
+		return false, nil
+	}
+	switch l := left.VariableInterface.(type) {
+	case *String:
+		return l.Value == right.VariableInterface.(*String).Value, nil
+	case *Boolean:
+		return l.Value == right.VariableInterface.(*Boolean).Value, nil
+	case *core.TypeVariable:
+		return l.Value == right.VariableInterface.(*core.TypeVariable).Value, nil
+	case *core.SetVariable:
+		return setEquals(l, right.VariableInterface.(*core.SetVariable))
+	}
+	if lt.Methods[core.EqualsMethod] != nil {
+		res := left.CallMethod(core.EqualsMethod, right.ToPointer())
+		if res.ReturnType != core.NOTHING {
+			return false, res
+		}
+		if res.Pointer == nil || res.Pointer.Variable == nil {
+			return false, core.NewExceptionReturn(core.EqualsMethod + " method of " + lt.Name + " did not return a boolean")
+		}
+		b, ok := res.Pointer.Variable.VariableInterface.(*Boolean)
+		if !ok {
+			return false, core.NewExceptionReturn(core.EqualsMethod + " method of " + lt.Name + " did not return a boolean")
+		}
+		return b.Value, nil
+	}
+	if lt.Methods[core.CompareMethod] != nil {
+		c, r := Compare(left, right)
+		return c == 0, r
+	}
+	return left.VariableInterface == right.VariableInterface, nil
+}
+
+// Compare orders two values. The result is negative, zero or positive when left is less than, equal to or greater
+// than right.
+func Compare(left *core.Variable, right *core.Variable) (int, *core.Return) {
+	if left == nil || right == nil {
+		return 0, core.NewExceptionReturn("cannot compare uninitialized values")
+	}
+	lt := left.GetType()
+	rt := right.GetType()
+	if isNumeric(lt) && isNumeric(rt) {
+		return compareNumbers(left, right)
+	}
+	if lt != rt {
+		return 0, core.NewExceptionReturn("cannot compare " + lt.Name + " with " + rt.Name)
+	}
+	if l, ok := left.VariableInterface.(*String); ok {
+		if r, ok := right.VariableInterface.(*String); ok {
+			return strings.Compare(l.Value, r.Value), nil
+		}
+	}
+	if lt.Methods[core.CompareMethod] != nil {
+		res := left.CallMethod(core.CompareMethod, right.ToPointer())
+		if res.ReturnType != core.NOTHING {
+			return 0, res
+		}
+		if res.Pointer == nil || res.Pointer.Variable == nil {
+			return 0, core.NewExceptionReturn(core.CompareMethod + " method of " + lt.Name + " did not return an integer")
+		}
+		i, ok := res.Pointer.Variable.VariableInterface.(*Integer)
+		if !ok {
+			return 0, core.NewExceptionReturn(core.CompareMethod + " method of " + lt.Name + " did not return an integer")
+		}
+		switch {
+		case i.Value < 0:
+			return -1, nil
+		case i.Value > 0:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, core.NewExceptionReturn(lt.Name + " values are not ordered")
+}
+
+func isNumeric(typ *core.Type) bool {
+	return typ == IntegerType || typ == BigIntegerType
+}
+
+func isSet(typ *core.Type) bool {
+	return typ == core.SetType || typ.Parent == core.SetType
+}
+
+func compareNumbers(left *core.Variable, right *core.Variable) (int, *core.Return) {
+	l, lok := left.VariableInterface.(*Integer)
+	r, rok := right.VariableInterface.(*Integer)
+	if lok && rok {
+		switch {
+		case l.Value < r.Value:
+			return -1, nil
+		case l.Value > r.Value:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return toBig(left).Cmp(toBig(right)), nil
+}
+
+func toBig(variable *core.Variable) *big.Int {
+	if i, ok := variable.VariableInterface.(*Integer); ok {
+		return big.NewInt(i.Value)
+	}
+	return variable.VariableInterface.(*BigInteger).Value
+}
+
+func setEquals(left *core.SetVariable, right *core.SetVariable) (bool, *core.Return) {
+	if len(left.Children) != len(right.Children) {
+		return false, nil
+	}
+	for i, child := range left.Children {
+		equal, r := Equals(child.Variable, right.Children[i].Variable)
+		if r != nil || !equal {
+			return false, r
+		}
+	}
+	return true, nil
+}
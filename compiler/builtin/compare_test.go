@@ -0,0 +1,59 @@
+package builtin_test
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"testing"
+)
+
+var versionType = &core.Type{Name: "Version", Parent: core.VariableType, Methods: map[string]core.Function{
+	core.CompareMethod: &compareVersionFunction{},
+}, Converters: map[*core.Type]core.Function{}, Scope: core.NewScope()}
+
+type version struct {
+	major int64
+}
+
+func (*version) GetType() *core.Type {
+	return versionType
+}
+
+type compareVersionFunction struct{}
+
+func (*compareVersionFunction) Execute(scope *core.Scope) *core.Return {
+	self := scope.MustGet(core.Self).Variable.VariableInterface.(*version)
+	other := scope.MustGet("other").Variable.VariableInterface.(*version)
+	return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewIntegerPointer(self.major - other.major)}
+}
+
+func (*compareVersionFunction) GetType() *core.Type {
+	return builtin.FunctionType
+}
+
+func (*compareVersionFunction) GetParameters() []*core.Parameter {
+	return []*core.Parameter{{Name: "other", Typ: versionType}}
+}
+
+func (*compareVersionFunction) GetReturnType() *core.Type {
+	return builtin.IntegerType
+}
+
+func (*compareVersionFunction) GetScope() *core.Scope {
+	return core.NewScope()
+}
+
+func TestCompareMethod(t *testing.T) {
+	if !builtin.IsOrdered(versionType, versionType) || !builtin.IsEquatable(versionType, versionType) {
+		t.Fatal("types with a compare method should be ordered and equatable")
+	}
+	one := core.NewVariable(&version{major: 1})
+	two := core.NewVariable(&version{major: 2})
+	c, exception := builtin.Compare(one, two)
+	if exception != nil || c >= 0 {
+		t.Fatalf("expected 1 < 2, got %d", c)
+	}
+	equal, exception := builtin.Equals(two, core.NewVariable(&version{major: 2}))
+	if exception != nil || !equal {
+		t.Fatal("expected versions to be equal")
+	}
+}
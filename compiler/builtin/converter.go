@@ -0,0 +1,40 @@
+package builtin
+
+import "github.com/cevatbarisyilmaz/selinus/compiler/core"
+
+// converterFunction adapts a Go conversion to the core.Function stored in the Converters of a type.
+type converterFunction struct {
+	typ     *core.Type
+	convert func(value core.VariableInterface) *core.Return
+}
+
+func newConverterFunction(name string, to *core.Type, convert func(value core.VariableInterface) *core.Return) *converterFunction {
+	return &converterFunction{
+		typ:     &core.Type{Parent: FunctionType, Name: name, Generic: true, Generics: []*core.Type{to}},
+		convert: convert,
+	}
+}
+
+func (converterFunction *converterFunction) Execute(scope *core.Scope) *core.Return {
+	getResult := scope.Get(core.Self)
+	if getResult.ReturnType != core.NOTHING {
+		return getResult
+	}
+	return converterFunction.convert(getResult.Pointer.Variable.VariableInterface)
+}
+
+func (converterFunction *converterFunction) GetType() *core.Type {
+	return converterFunction.typ
+}
+
+func (converterFunction *converterFunction) GetParameters() []*core.Parameter {
+	return nil
+}
+
+func (converterFunction *converterFunction) GetReturnType() *core.Type {
+	return converterFunction.typ.Generics[0]
+}
+
+func (converterFunction *converterFunction) GetScope() *core.Scope {
+	return scope
+}
@@ -3,10 +3,12 @@ package builtin
 import (
 	"fmt"
 	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
 )
 
 var IntegerType = &core.Type{Name: "Integer", Parent: core.VariableType, Methods: map[string]core.Function{}, Converters: map[*core.Type]core.Function{
-	StringType: &IntegerToStringConverterFunction{},
+	StringType:     &IntegerToStringConverterFunction{},
+	BigIntegerType: &IntegerToBigIntegerConverterFunction{},
 }, Scope: core.NewScope()}
 
 type Integer struct {
@@ -17,11 +19,18 @@ func (*Integer) GetType() *core.Type {
 	return IntegerType
 }
 
+// integerPointer lets NewIntegerPointer allocate a pointer together with its variable and value.
+type integerPointer struct {
+	pointer  core.Pointer
+	variable core.Variable
+	integer  Integer
+}
+
 func NewIntegerPointer(value int64) *core.Pointer {
-	return &core.Pointer{
-		Typ:      IntegerType,
-		Variable: core.NewVariable(&Integer{Value: value}),
-	}
+	p := &integerPointer{integer: Integer{Value: value}}
+	p.variable.VariableInterface = &p.integer
+	p.pointer = core.Pointer{Typ: IntegerType, Variable: &p.variable}
+	return &p.pointer
 }
 
 var IntegerToStringConverterFunctionType = &core.Type{Parent: FunctionType, Name: "convertIntegerToString", Generic: true, Generics: []*core.Type{StringType}}
@@ -55,3 +64,40 @@ func (integerToStringConverterFunction *IntegerToStringConverterFunction) GetRet
 func (integerToStringConverterFunction *IntegerToStringConverterFunction) GetScope() *core.Scope {
 	return scope
 }
+
+var IntegerToBigIntegerConverterFunctionType = &core.Type{Parent: FunctionType, Name: "convertIntegerToBigInteger", Generic: true, Generics: []*core.Type{BigIntegerType}}
+
+type IntegerToBigIntegerConverterFunction struct{}
+
+func (integerToBigIntegerConverterFunction *IntegerToBigIntegerConverterFunction) Execute(scope *core.Scope) *core.Return {
+	getResult := scope.Get(core.Self)
+	if getResult.ReturnType != core.NOTHING {
+		return getResult
+	}
+	return &core.Return{
+		ReturnType: core.NOTHING,
+		Pointer:    NewBigIntegerPointer(big.NewInt(getResult.Pointer.Variable.VariableInterface.(*Integer).Value)),
+	}
+}
+
+func (integerToBigIntegerConverterFunction *IntegerToBigIntegerConverterFunction) GetType() *core.Type {
+	return IntegerToBigIntegerConverterFunctionType
+}
+
+func (integerToBigIntegerConverterFunction *IntegerToBigIntegerConverterFunction) GetParameters() []*core.Parameter {
+	return nil
+}
+
+func (integerToBigIntegerConverterFunction *IntegerToBigIntegerConverterFunction) GetReturnType() *core.Type {
+	return BigIntegerType
+}
+
+func (integerToBigIntegerConverterFunction *IntegerToBigIntegerConverterFunction) GetScope() *core.Scope {
+	return scope
+}
+
+func init() {
+	IntegerType.Converters[BooleanType] = newConverterFunction("convertIntegerToBoolean", BooleanType, func(value core.VariableInterface) *core.Return {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewBooleanPointer(value.(*Integer).Value != 0)}
+	})
+}
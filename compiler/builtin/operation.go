@@ -0,0 +1,255 @@
+package builtin
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
+)
+
+// Operator identifies a binary operation of the language. The operations are shared by every backend so that they
+// behave and fail the same way.
+type Operator int
+
+const (
+	Addition Operator = iota
+	Subtraction
+	Multiplication
+	Division
+	Equal
+	NotEqual
+	Greater
+	GreaterOrEqual
+	Less
+	LessOrEqual
+)
+
+// The compiler checks operand types, but values can still reach an operation with an unexpected type at runtime, e.g.
+// through variables declared as var. The functions below unwrap values and report such mismatches as exceptions.
+
+func variableOf(pointer *core.Pointer, expected *core.Type) (*core.Variable, *core.Return) {
+	if pointer == nil || pointer.Variable == nil {
+		return nil, core.NewExceptionReturn("expected " + expected.Name + " but got nothing")
+	}
+	return pointer.Variable, nil
+}
+
+func typeMismatch(variable *core.Variable, expected *core.Type) *core.Return {
+	return core.NewExceptionReturn("expected " + expected.Name + " but got " + variable.GetType().Name)
+}
+
+// SizeOf returns the number of bytes a value is charged with by core.Thread.Allocate. Only values whose size depends
+// on the program are charged: strings, big integers, sets and the buffers of channels.
+func SizeOf(pointer *core.Pointer) int64 {
+	if pointer == nil || pointer.Variable == nil {
+		return 0
+	}
+	switch value := pointer.Variable.VariableInterface.(type) {
+	case *String:
+		return int64(len(value.Value))
+	case *BigInteger:
+		return int64((value.Value.BitLen() + 7) / 8)
+	case *core.SetVariable:
+		return 8 * int64(len(value.Children))
+	case *Channel:
+		return 8 * int64(value.size)
+	}
+	return 0
+}
+
+// Charge counts the value created by an operation against the memory limit of thread. A value the operation returned
+// from its operand is not charged again.
+func Charge(thread *core.Thread, value *core.Pointer, operand *core.Pointer) *core.Return {
+	if value == nil || (operand != nil && value.Variable == operand.Variable) {
+		return nil
+	}
+	return thread.Allocate(SizeOf(value))
+}
+
+// Convert converts the value to the given type.
+func Convert(pointer *core.Pointer, typ *core.Type) *core.Return {
+	variable, exception := variableOf(pointer, typ)
+	if exception != nil {
+		return exception
+	}
+	return variable.ConvertTo(typ)
+}
+
+func IntegerOf(pointer *core.Pointer) (int64, *core.Return) {
+	variable, exception := variableOf(pointer, IntegerType)
+	if exception != nil {
+		return 0, exception
+	}
+	i, ok := variable.VariableInterface.(*Integer)
+	if !ok {
+		return 0, typeMismatch(variable, IntegerType)
+	}
+	return i.Value, nil
+}
+
+func BooleanOf(pointer *core.Pointer) (bool, *core.Return) {
+	variable, exception := variableOf(pointer, BooleanType)
+	if exception != nil {
+		return false, exception
+	}
+	b, ok := variable.VariableInterface.(*Boolean)
+	if !ok {
+		return false, typeMismatch(variable, BooleanType)
+	}
+	return b.Value, nil
+}
+
+func StringOf(pointer *core.Pointer) (string, *core.Return) {
+	variable, exception := variableOf(pointer, StringType)
+	if exception != nil {
+		return "", exception
+	}
+	s, ok := variable.VariableInterface.(*String)
+	if !ok {
+		return "", typeMismatch(variable, StringType)
+	}
+	return s.Value, nil
+}
+
+func BigIntegerOf(pointer *core.Pointer) (*big.Int, *core.Return) {
+	variable, exception := variableOf(pointer, BigIntegerType)
+	if exception != nil {
+		return nil, exception
+	}
+	b, ok := variable.VariableInterface.(*BigInteger)
+	if !ok {
+		return nil, typeMismatch(variable, BigIntegerType)
+	}
+	return b.Value, nil
+}
+
+// ToBoolean converts the value to a boolean before unwrapping it.
+func ToBoolean(pointer *core.Pointer) (bool, *core.Return) {
+	r := Convert(pointer, BooleanType)
+	if r.ReturnType != core.NOTHING {
+		return false, r
+	}
+	return BooleanOf(r.Pointer)
+}
+
+// ToInteger converts the value to an integer before unwrapping it.
+func ToInteger(pointer *core.Pointer) (int64, *core.Return) {
+	r := Convert(pointer, IntegerType)
+	if r.ReturnType != core.NOTHING {
+		return 0, r
+	}
+	return IntegerOf(r.Pointer)
+}
+
+// ToString converts the value to a string before unwrapping it.
+func ToString(pointer *core.Pointer) (string, *core.Return) {
+	r := Convert(pointer, StringType)
+	if r.ReturnType != core.NOTHING {
+		return "", r
+	}
+	return StringOf(r.Pointer)
+}
+
+// ToBigInteger converts the value to a big integer before unwrapping it.
+func ToBigInteger(pointer *core.Pointer) (*big.Int, *core.Return) {
+	r := Convert(pointer, BigIntegerType)
+	if r.ReturnType != core.NOTHING {
+		return nil, r
+	}
+	return BigIntegerOf(r.Pointer)
+}
+
+// Arithmetic applies an arithmetic operator to two integers.
+func Arithmetic(operator Operator, left *core.Pointer, right *core.Pointer) (*core.Pointer, *core.Return) {
+	x, exception := IntegerOf(left)
+	if exception != nil {
+		return nil, exception
+	}
+	y, exception := IntegerOf(right)
+	if exception != nil {
+		return nil, exception
+	}
+	var z int64
+	switch operator {
+	case Addition:
+		z = x + y
+	case Subtraction:
+		z = x - y
+	case Multiplication:
+		z = x * y
+	case Division:
+		if y == 0 {
+			return nil, core.NewExceptionReturn("division by zero")
+		}
+		z = x / y
+	}
+	return NewIntegerPointer(z), nil
+}
+
+// BigIntegerArithmetic applies an arithmetic operator to two big integers.
+func BigIntegerArithmetic(operator Operator, left *core.Pointer, right *core.Pointer) (*core.Pointer, *core.Return) {
+	x, exception := BigIntegerOf(left)
+	if exception != nil {
+		return nil, exception
+	}
+	y, exception := BigIntegerOf(right)
+	if exception != nil {
+		return nil, exception
+	}
+	z := new(big.Int)
+	switch operator {
+	case Addition:
+		z.Add(x, y)
+	case Subtraction:
+		z.Sub(x, y)
+	case Multiplication:
+		z.Mul(x, y)
+	case Division:
+		if y.Sign() == 0 {
+			return nil, core.NewExceptionReturn("division by zero")
+		}
+		z.Quo(x, y)
+	}
+	return NewBigIntegerPointer(z), nil
+}
+
+// Concatenate joins two strings.
+func Concatenate(left *core.Pointer, right *core.Pointer) (*core.Pointer, *core.Return) {
+	x, exception := StringOf(left)
+	if exception != nil {
+		return nil, exception
+	}
+	y, exception := StringOf(right)
+	if exception != nil {
+		return nil, exception
+	}
+	return NewStringPointer(x + y), nil
+}
+
+// Comparison applies an equality or ordering operator to two values.
+func Comparison(operator Operator, left *core.Pointer, right *core.Pointer) (*core.Pointer, *core.Return) {
+	if left == nil || right == nil {
+		return nil, core.NewExceptionReturn("cannot compare uninitialized values")
+	}
+	if operator == Equal || operator == NotEqual {
+		equal, exception := Equals(left.Variable, right.Variable)
+		if exception != nil {
+			return nil, exception
+		}
+		return NewBooleanPointer(equal == (operator == Equal)), nil
+	}
+	c, exception := Compare(left.Variable, right.Variable)
+	if exception != nil {
+		return nil, exception
+	}
+	var result bool
+	switch operator {
+	case Greater:
+		result = c > 0
+	case GreaterOrEqual:
+		result = c >= 0
+	case Less:
+		result = c < 0
+	case LessOrEqual:
+		result = c <= 0
+	}
+	return NewBooleanPointer(result), nil
+}
@@ -0,0 +1,209 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
+	"reflect"
+)
+
+// FromGoValue converts a Go value to a Selinus value. Integers become Integer, *big.Int becomes BigInteger, strings
+// become String, booleans become Boolean and []interface{} becomes a Set of the converted elements.
+func FromGoValue(value interface{}) (*core.Pointer, error) {
+	switch v := value.(type) {
+	case int:
+		return NewIntegerPointer(int64(v)), nil
+	case int32:
+		return NewIntegerPointer(int64(v)), nil
+	case int64:
+		return NewIntegerPointer(v), nil
+	case *big.Int:
+		if v == nil {
+			return nil, errors.New("nil *big.Int has no Selinus equivalent")
+		}
+		return NewBigIntegerPointer(new(big.Int).Set(v)), nil
+	case string:
+		return NewStringPointer(v), nil
+	case bool:
+		return NewBooleanPointer(v), nil
+	case []interface{}:
+		children := make([]*core.Pointer, len(v))
+		for i, child := range v {
+			pointer, err := FromGoValue(child)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = pointer
+		}
+		return core.NewSetPointer(children), nil
+	}
+	return nil, fmt.Errorf("%T has no Selinus equivalent", value)
+}
+
+// GoValueOf converts a Selinus value to a Go value, the reverse of FromGoValue. Integers are returned as int64 and
+// values of other types, such as functions, are returned as they are.
+func GoValueOf(pointer *core.Pointer) interface{} {
+	if pointer == nil || pointer.Variable == nil {
+		return nil
+	}
+	switch v := pointer.Variable.VariableInterface.(type) {
+	case *Integer:
+		return v.Value
+	case *BigInteger:
+		return new(big.Int).Set(v.Value)
+	case *String:
+		return v.Value
+	case *Boolean:
+		return v.Value
+	case *core.SetVariable:
+		children := make([]interface{}, len(v.Children))
+		for i, child := range v.Children {
+			children[i] = GoValueOf(child)
+		}
+		return children
+	}
+	return pointer.Variable.VariableInterface
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// goTypes are the Go types a function passed to NewFunctionPointer can take and return, with their Selinus types.
+var goTypes = map[reflect.Type]*core.Type{
+	reflect.TypeOf(int(0)):                     IntegerType,
+	reflect.TypeOf(int32(0)):                   IntegerType,
+	reflect.TypeOf(int64(0)):                   IntegerType,
+	reflect.TypeOf((*big.Int)(nil)):            BigIntegerType,
+	reflect.TypeOf(""):                         StringType,
+	reflect.TypeOf(false):                      BooleanType,
+	reflect.TypeOf([]interface{}(nil)):         core.SetType,
+	reflect.TypeOf((*interface{})(nil)).Elem(): core.VariableType,
+}
+
+// goFunction is a Go function called from Selinus, see NewFunctionPointer.
+type goFunction struct {
+	name       string
+	function   reflect.Value
+	typ        *core.Type
+	parameters []*core.Parameter
+	returnType *core.Type
+}
+
+// NewFunctionPointer wraps an ordinary Go function so that Selinus programs can call it under the given name. The
+// Selinus signature is derived from the signature of fn, whose parameters and result must be of the types listed in
+// goTypes. fn returns at most one value, optionally followed by an error that is raised as an exception.
+func NewFunctionPointer(name string, fn interface{}) (*core.Pointer, error) {
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func || function.IsNil() {
+		return nil, fmt.Errorf("%s: expected a function but got %T", name, fn)
+	}
+	signature := function.Type()
+	if signature.IsVariadic() {
+		return nil, fmt.Errorf("%s: variadic functions are not supported", name)
+	}
+	g := &goFunction{name: name, function: function}
+	for i := 0; i < signature.NumIn(); i++ {
+		typ, ok := goTypes[signature.In(i)]
+		if !ok {
+			return nil, fmt.Errorf("%s: parameter type %s is not supported", name, signature.In(i))
+		}
+		g.parameters = append(g.parameters, &core.Parameter{Name: fmt.Sprintf("arg%d", i+1), Typ: typ})
+	}
+	results := signature.NumOut()
+	if results > 0 && signature.Out(results-1) == errorType {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("%s: functions can return at most one value and an error", name)
+	}
+	if results == 1 {
+		typ, ok := goTypes[signature.Out(0)]
+		if !ok {
+			return nil, fmt.Errorf("%s: return type %s is not supported", name, signature.Out(0))
+		}
+		g.returnType = typ
+	}
+	generics := []*core.Type{g.returnType}
+	for _, parameter := range g.parameters {
+		generics = append(generics, parameter.Typ)
+	}
+	g.typ = &core.Type{Name: name, Parent: FunctionType, Generic: true, Generics: generics}
+	return &core.Pointer{Typ: g.typ, Variable: core.NewVariable(g), Immutable: true}, nil
+}
+
+// MustNewFunctionPointer is like NewFunctionPointer but panics when fn can not be wrapped, it is meant for natives
+// declared in package variables.
+func MustNewFunctionPointer(name string, fn interface{}) *core.Pointer {
+	pointer, err := NewFunctionPointer(name, fn)
+	if err != nil {
+		panic(err)
+	}
+	return pointer
+}
+
+// Execute converts the arguments in the frame of scope to Go values, calls the function and converts its result back.
+func (g *goFunction) Execute(scope *core.Scope) *core.Return {
+	signature := g.function.Type()
+	arguments := make([]reflect.Value, len(g.parameters))
+	for i := range g.parameters {
+		value := GoValueOf(scope.Frame.Slots[i])
+		if value == nil {
+			arguments[i] = reflect.Zero(signature.In(i))
+			continue
+		}
+		argument := reflect.ValueOf(value)
+		if !argument.Type().ConvertibleTo(signature.In(i)) {
+			return core.NewExceptionReturn(fmt.Sprintf("%s: expected %s but got %s", g.name, g.parameters[i].Typ.Name, scope.Frame.Slots[i].Variable.GetType().Name))
+		}
+		// Integers are int64, converting them to a narrower parameter type would silently truncate them.
+		if integer, ok := value.(int64); ok && overflows(signature.In(i), integer) {
+			return core.NewExceptionReturn(fmt.Sprintf("%s: %d is out of the range of %s", g.name, integer, signature.In(i)))
+		}
+		arguments[i] = argument.Convert(signature.In(i))
+	}
+	results := g.function.Call(arguments)
+	if len(results) > 0 && signature.Out(len(results)-1) == errorType {
+		if err, _ := results[len(results)-1].Interface().(error); err != nil {
+			return core.NewExceptionReturn(g.name + ": " + err.Error())
+		}
+		results = results[:len(results)-1]
+	}
+	if len(results) == 0 {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+	}
+	pointer, err := FromGoValue(results[0].Interface())
+	if err != nil {
+		return core.NewExceptionReturn(g.name + ": " + err.Error())
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+// overflows reports whether value does not fit in typ when typ is an integer type.
+func overflows(typ reflect.Type, value int64) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return reflect.Zero(typ).OverflowInt(value)
+	}
+	return false
+}
+
+func (g *goFunction) GetType() *core.Type {
+	return g.typ
+}
+
+func (g *goFunction) GetParameters() []*core.Parameter {
+	return g.parameters
+}
+
+func (g *goFunction) GetReturnType() *core.Type {
+	return g.returnType
+}
+
+// GetScope returns a scope without variables, the arguments are passed in the slots of a new frame.
+func (g *goFunction) GetScope() *core.Scope {
+	return &core.Scope{Name: g.name}
+}
+
+func (g *goFunction) GetFrameSize() int {
+	return len(g.parameters)
+}
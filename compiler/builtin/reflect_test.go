@@ -0,0 +1,60 @@
+package builtin_test
+
+import (
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"strings"
+	"testing"
+)
+
+func TestFunctionPointerSignature(t *testing.T) {
+	pointer, err := builtin.NewFunctionPointer("repeat", func(n int64, s string) (string, error) {
+		return strings.Repeat(s, int(n)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generics := pointer.Typ.Generics
+	if len(generics) != 3 || generics[0] != builtin.StringType || generics[1] != builtin.IntegerType || generics[2] != builtin.StringType {
+		t.Fatal("unexpected signature ", generics)
+	}
+	for _, fn := range []interface{}{nil, 42, func(float64) {}, func() (int, string) { return 0, "" }, func(...int) {}} {
+		if _, err := builtin.NewFunctionPointer("invalid", fn); err == nil {
+			t.Fatalf("expected %T to be rejected", fn)
+		}
+	}
+}
+
+func TestFunctionPointerCall(t *testing.T) {
+	pointer := builtin.MustNewFunctionPointer("half", func(n int) (int, error) {
+		if n%2 != 0 {
+			return 0, errors.New("odd number")
+		}
+		return n / 2, nil
+	})
+	function := pointer.Variable.VariableInterface.(core.Function)
+	res := core.Call(nil, function, []*core.Pointer{builtin.NewIntegerPointer(42)}, "half")
+	if value, exception := builtin.IntegerOf(res.Pointer); exception != nil || value != 21 {
+		t.Fatal("half(42) returned ", res)
+	}
+	res = core.Call(nil, function, []*core.Pointer{builtin.NewIntegerPointer(3)}, "half")
+	if res.ReturnType != core.EXCEPTION || res.Pointer.Variable.VariableInterface.(*core.StackTrace).ExceptionMessage != "half: odd number" {
+		t.Fatal("expected half(3) to raise an exception")
+	}
+}
+
+func TestFunctionPointerIntegerRange(t *testing.T) {
+	pointer := builtin.MustNewFunctionPointer("narrow", func(n int32) int32 {
+		return n
+	})
+	function := pointer.Variable.VariableInterface.(core.Function)
+	res := core.Call(nil, function, []*core.Pointer{builtin.NewIntegerPointer(-1 << 31)}, "narrow")
+	if value, exception := builtin.IntegerOf(res.Pointer); exception != nil || value != -1<<31 {
+		t.Fatal("narrow(-1 << 31) returned ", res)
+	}
+	res = core.Call(nil, function, []*core.Pointer{builtin.NewIntegerPointer(1 << 31)}, "narrow")
+	if res.ReturnType != core.EXCEPTION || res.Pointer.Variable.VariableInterface.(*core.StackTrace).ExceptionMessage != "narrow: 2147483648 is out of the range of int32" {
+		t.Fatal("expected narrow(1 << 31) to raise an exception, got ", res)
+	}
+}
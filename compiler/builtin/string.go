@@ -1,6 +1,10 @@
 package builtin
 
-import "github.com/cevatbarisyilmaz/selinus/compiler/core"
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"math/big"
+	"strconv"
+)
 
 var StringType = &core.Type{Name: "String", Parent: core.VariableType, Methods: map[string]core.Function{}, Converters: map[*core.Type]core.Function{}, Scope: core.NewScope()}
 
@@ -22,3 +26,24 @@ func NewStringPointer(value string) *core.Pointer {
 		Variable: core.NewVariable(&String{Value: value}),
 	}
 }
+
+func init() {
+	StringType.Converters[IntegerType] = newConverterFunction("convertStringToInteger", IntegerType, func(value core.VariableInterface) *core.Return {
+		i, err := strconv.ParseInt(value.(*String).Value, 10, 64)
+		if err != nil {
+			return core.NewExceptionReturn("cannot convert " + strconv.Quote(value.(*String).Value) + " to " + IntegerType.Name)
+		}
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewIntegerPointer(i)}
+	})
+	StringType.Converters[BigIntegerType] = newConverterFunction("convertStringToBigInteger", BigIntegerType, func(value core.VariableInterface) *core.Return {
+		i, ok := new(big.Int).SetString(value.(*String).Value, 10)
+		if !ok {
+			return core.NewExceptionReturn("cannot convert " + strconv.Quote(value.(*String).Value) + " to " + BigIntegerType.Name)
+		}
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewBigIntegerPointer(i)}
+	})
+	// Strings are truthy unless they are empty.
+	StringType.Converters[BooleanType] = newConverterFunction("convertStringToBoolean", BooleanType, func(value core.VariableInterface) *core.Return {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: NewBooleanPointer(value.(*String).Value != "")}
+	})
+}
@@ -0,0 +1,400 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"math/big"
+)
+
+// emitter translates a compiled tree to bytecode for the virtual machine. Every instruction records the node it was
+// compiled from, so that exceptions raised by the machine carry the same stack trace as the tree walker produces.
+type emitter struct {
+	program   *vm.Program
+	prototype *vm.Prototype
+	constants map[*core.Pointer]int32
+	types     map[*core.Type]int32
+	names     map[string]int32
+}
+
+// CompileBytecode translates the tree returned by Compile to a program for the virtual machine.
+func CompileBytecode(root core.Node) *vm.Program {
+	e := &emitter{
+		program:   &vm.Program{Main: &vm.Prototype{Name: "main"}, References: make(map[int32]string)},
+		constants: make(map[*core.Pointer]int32),
+		types:     make(map[*core.Type]int32),
+		names:     make(map[string]int32),
+	}
+	e.prototype = e.program.Main
+	e.block(root, -1)
+	return e.program
+}
+
+// CompileProgram compiles a program against the scope and translates it to bytecode. Unlike CompileBytecode, the
+// result records the global variables and the declarations of the program, so that it can be serialized.
+func CompileProgram(node *parser.ParseNode, scope *core.Scope) (*vm.Program, error) {
+	base := len(scope.Frame.Slots)
+	root, err := Compile(node, scope)
+	if err != nil {
+		return nil, err
+	}
+	program := CompileBytecode(root)
+	program.Base = base
+	program.Globals = len(scope.Frame.Slots) - base
+	program.Exports = scope.CurrentBlock().References
+	return program, nil
+}
+
+func (e *emitter) emit(opcode vm.Opcode, a int32, b int32, c int32, origin int32) int {
+	e.prototype.Code = append(e.prototype.Code, vm.Instruction{Opcode: opcode, A: a, B: b, C: c, Origin: origin})
+	return len(e.prototype.Code) - 1
+}
+
+// patch points the jump at index to the next instruction.
+func (e *emitter) patch(index int) {
+	e.prototype.Code[index].A = int32(len(e.prototype.Code))
+}
+
+func (e *emitter) origin(node core.Node, parent int32) int32 {
+	e.program.Origins = append(e.program.Origins, vm.Origin{Position: node.Position(), Parent: parent})
+	return int32(len(e.program.Origins) - 1)
+}
+
+func (e *emitter) constant(pointer *core.Pointer) int32 {
+	index, ok := e.constants[pointer]
+	if !ok {
+		e.program.Constants = append(e.program.Constants, pointer)
+		index = int32(len(e.program.Constants) - 1)
+		e.constants[pointer] = index
+	}
+	return index
+}
+
+func (e *emitter) typ(typ *core.Type) int32 {
+	index, ok := e.types[typ]
+	if !ok {
+		e.program.Types = append(e.program.Types, typ)
+		index = int32(len(e.program.Types) - 1)
+		e.types[typ] = index
+	}
+	return index
+}
+
+func (e *emitter) name(name string) int32 {
+	index, ok := e.names[name]
+	if !ok {
+		e.program.Names = append(e.program.Names, name)
+		index = int32(len(e.program.Names) - 1)
+		e.names[name] = index
+	}
+	return index
+}
+
+// declaration returns the index of a constant holding a declaration that has no slot. Unless the declaration is a
+// constant, it belongs to the scope the program is compiled against and is recorded as a reference to it.
+func (e *emitter) declaration(pointer *core.Pointer, name string) int32 {
+	index := e.constant(pointer)
+	if !pointer.Constant {
+		e.program.References[index] = name
+	}
+	return index
+}
+
+func slotOf(declaration *core.Pointer) int32 {
+	if declaration == nil {
+		return -1
+	}
+	return int32(declaration.Slot.Index)
+}
+
+func (e *emitter) block(node core.Node, parent int32) {
+	for ; node != nil; node = node.Next() {
+		e.statement(node, parent)
+	}
+}
+
+func (e *emitter) statement(node core.Node, parent int32) {
+	switch root := node.Root().(type) {
+	case *ConditionNode:
+		origin := e.origin(node, parent)
+		e.expression(root.condition, origin)
+		otherwise := e.emit(vm.OpJumpIfFalse, 0, 0, 0, origin)
+		e.block(root.root, origin)
+		if root.otherwise == nil {
+			e.patch(otherwise)
+			return
+		}
+		end := e.emit(vm.OpJump, 0, 0, 0, origin)
+		e.patch(otherwise)
+		e.block(root.otherwise, origin)
+		e.patch(end)
+	case *ToLoopNode:
+		origin := e.origin(node, parent)
+		e.expression(root.from, origin)
+		e.emit(vm.OpToInteger, 0, 0, 0, origin)
+		e.expression(root.to, origin)
+		e.emit(vm.OpToInteger, 0, 0, 0, origin)
+		e.emit(vm.OpLoopStart, slotOf(root.declaration), 0, 0, origin)
+		next := e.emit(vm.OpLoopNext, 0, 0, 0, origin)
+		e.block(root.root, origin)
+		e.emit(vm.OpJump, int32(next), 0, 0, origin)
+		e.patch(next)
+	case *ConditionLoopNode:
+		origin := e.origin(node, parent)
+		condition := len(e.prototype.Code)
+		e.expression(root.condition, origin)
+		end := e.emit(vm.OpJumpIfFalse, 0, 0, 0, origin)
+		e.emit(vm.OpStep, 0, 0, 0, origin)
+		e.block(root.root, origin)
+		e.emit(vm.OpJump, int32(condition), 0, 0, origin)
+		e.patch(end)
+	case *ReturnNode:
+		origin := e.origin(node, parent)
+		e.expression(root.node, origin)
+		e.emit(vm.OpReturn, 0, 0, 0, origin)
+	case *ResultNode:
+		e.expression(root.node, parent)
+		e.emit(vm.OpReturn, 0, 0, 0, parent)
+	case *SpawnNode:
+		origin := e.origin(node, parent)
+		call := root.call.Root().(*FunctionCallNode)
+		callOrigin := e.origin(root.call, origin)
+		e.call(call, callOrigin)
+		e.emit(vm.OpSpawn, int32(len(call.parameters)), 0, e.name(call.name), callOrigin)
+	case *JoinNode:
+		e.emit(vm.OpJoin, 0, 0, 0, e.origin(node, parent))
+	case *SelectNode:
+		e.selection(node, root, parent)
+	default:
+		e.expression(node, parent)
+		e.emit(vm.OpPop, 0, 0, 0, parent)
+	}
+}
+
+// binary compiles both operands of an operation, converting them to typ first when it is not nil.
+func (e *emitter) binary(left core.Node, right core.Node, typ *core.Type, origin int32) {
+	e.expression(left, origin)
+	if typ != nil {
+		e.emit(vm.OpConvert, e.typ(typ), 0, 0, origin)
+	}
+	e.expression(right, origin)
+	if typ != nil {
+		e.emit(vm.OpConvert, e.typ(typ), 0, 0, origin)
+	}
+}
+
+// expression compiles a node that leaves exactly one value on the stack.
+func (e *emitter) expression(node core.Node, parent int32) {
+	if node == nil {
+		return
+	}
+	origin := e.origin(node, parent)
+	switch root := node.Root().(type) {
+	case *IntegerNode:
+		e.emit(vm.OpConstant, e.constant(builtin.NewIntegerPointer(root.value)), 0, 0, origin)
+	case *BigIntegerNode:
+		e.emit(vm.OpConstant, e.constant(builtin.NewBigIntegerPointer(root.value)), 0, 0, origin)
+	case *StringNode:
+		e.emit(vm.OpConstant, e.constant(builtin.NewStringPointer(root.value)), 0, 0, origin)
+	case *BooleanNode:
+		e.emit(vm.OpConstant, e.constant(builtin.NewBooleanPointer(root.value)), 0, 0, origin)
+	case *ConstantNode:
+		e.emit(vm.OpConstant, e.constant(root.pointer), 0, 0, origin)
+	case *ConstantDeclarationNode:
+		e.emit(vm.OpConstant, e.constant(root.pointer), 0, 0, origin)
+	case *VariableNode:
+		if root.declaration.Slot == nil {
+			e.emit(vm.OpConstant, e.declaration(root.declaration, root.name), 0, 0, origin)
+			break
+		}
+		opcode := vm.OpLoad
+		if root.unproven {
+			opcode = vm.OpLoadAssigned
+		}
+		e.emit(opcode, int32(root.depth), int32(root.slot), e.name(root.name), origin)
+	case *DeclarationNode:
+		e.emit(vm.OpDeclare, slotOf(root.declaration), e.typ(root.typ), 0, origin)
+	case *SetNode:
+		e.expression(root.rightSide, origin)
+		e.expression(root.leftSide, origin)
+		e.emit(vm.OpSet, 0, 0, 0, origin)
+	case *ConversionNode:
+		e.expression(root.node, origin)
+		e.emit(vm.OpConvert, e.typ(root.typ), 0, 0, origin)
+	case *OrNode:
+		e.expression(root.left, origin)
+		e.emit(vm.OpToBoolean, 0, 0, 0, origin)
+		end := e.emit(vm.OpJumpIfTrueOrPop, 0, 0, 0, origin)
+		e.expression(root.right, origin)
+		e.emit(vm.OpToBoolean, 0, 0, 0, origin)
+		e.patch(end)
+	case *AndNode:
+		e.expression(root.left, origin)
+		e.emit(vm.OpToBoolean, 0, 0, 0, origin)
+		end := e.emit(vm.OpJumpIfFalseOrPop, 0, 0, 0, origin)
+		e.expression(root.right, origin)
+		e.emit(vm.OpToBoolean, 0, 0, 0, origin)
+		e.patch(end)
+	case *SummationNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpArithmetic, int32(builtin.Addition), 0, 0, origin)
+	case *SubtractionNode:
+		if root.left == nil {
+			e.emit(vm.OpConstant, e.constant(builtin.NewIntegerPointer(0)), 0, 0, origin)
+			e.expression(root.right, origin)
+		} else {
+			e.binary(root.left, root.right, nil, origin)
+		}
+		e.emit(vm.OpArithmetic, int32(builtin.Subtraction), 0, 0, origin)
+	case *MultiplicationNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpArithmetic, int32(builtin.Multiplication), 0, 0, origin)
+	case *DivisionNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpArithmetic, int32(builtin.Division), 0, 0, origin)
+	case *BigIntegerArithmeticNode:
+		left := root.left
+		if left == nil {
+			e.emit(vm.OpConstant, e.constant(builtin.NewBigIntegerPointer(new(big.Int))), 0, 0, origin)
+			e.emit(vm.OpConvert, e.typ(builtin.BigIntegerType), 0, 0, origin)
+			e.expression(root.right, origin)
+			e.emit(vm.OpConvert, e.typ(builtin.BigIntegerType), 0, 0, origin)
+		} else {
+			e.binary(left, root.right, builtin.BigIntegerType, origin)
+		}
+		e.emit(vm.OpBigIntegerArithmetic, int32(root.operator), 0, 0, origin)
+	case *ConcatenationNode:
+		e.binary(root.left, root.right, builtin.StringType, origin)
+		e.emit(vm.OpConcatenate, 0, 0, 0, origin)
+	case *EqualityNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.Equal), 0, 0, origin)
+	case *InequalityNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.NotEqual), 0, 0, origin)
+	case *GreaterNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.Greater), 0, 0, origin)
+	case *GreaterOrEqualNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.GreaterOrEqual), 0, 0, origin)
+	case *LessNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.Less), 0, 0, origin)
+	case *LessOrEqualNode:
+		e.binary(root.left, root.right, nil, origin)
+		e.emit(vm.OpCompare, int32(builtin.LessOrEqual), 0, 0, origin)
+	case *CsvNode:
+		for _, child := range root.children {
+			e.expression(child, origin)
+		}
+		e.emit(vm.OpMakeSet, int32(len(root.children)), 0, 0, origin)
+	case *FunctionCallNode:
+		e.call(root, origin)
+		opcode := vm.OpCall
+		if root.tail {
+			opcode = vm.OpTailCall
+		}
+		e.emit(opcode, int32(len(root.parameters)), 0, e.name(root.name), origin)
+	case *ChannelNode:
+		e.expression(root.size, origin)
+		size := int32(0)
+		if root.size != nil {
+			size = 1
+		}
+		e.emit(vm.OpChannel, e.typ(root.element), size, 0, origin)
+	case *SendNode:
+		e.expression(root.channel, origin)
+		e.expression(root.value, origin)
+		e.emit(vm.OpSend, 0, 0, 0, origin)
+	case *ReceiveNode:
+		e.expression(root.channel, origin)
+		e.emit(vm.OpReceive, 0, 0, 0, origin)
+	case *CloseNode:
+		e.expression(root.channel, origin)
+		e.emit(vm.OpClose, 0, 0, 0, origin)
+	case *FunctionNode:
+		slot := int32(-1)
+		if !root.lambda {
+			slot = slotOf(root.declaration)
+		}
+		e.emit(vm.OpClosure, e.function(root), slot, 0, origin)
+	default:
+		panic(fmt.Sprintf("%T can not be compiled to bytecode", root))
+	}
+}
+
+// call pushes the function a call is made to and its arguments.
+func (e *emitter) call(node *FunctionCallNode, origin int32) {
+	if node.declaration.Slot == nil {
+		e.emit(vm.OpLoadFunction, -1, e.declaration(node.declaration, node.name), e.name(node.name), origin)
+	} else {
+		e.emit(vm.OpLoadFunction, int32(node.depth), int32(node.slot), e.name(node.name), origin)
+	}
+	for _, parameter := range node.parameters {
+		e.expression(parameter, origin)
+	}
+}
+
+// selection compiles a select to the operands of its cases, an OpSelect with an OpSelectCase for every case, and the
+// bodies of the cases followed by the else case, each of which jumps past the others when it is done.
+func (e *emitter) selection(node core.Node, root *SelectNode, parent int32) {
+	origin := e.origin(node, parent)
+	for _, c := range root.cases {
+		caseRoot := c.Root().(*SelectCaseNode)
+		caseOrigin := e.origin(c, origin)
+		e.expression(caseRoot.channel, caseOrigin)
+		e.expression(caseRoot.value, caseOrigin)
+	}
+	selection := e.emit(vm.OpSelect, int32(len(root.cases)), 0, -1, origin)
+	descriptors := make([]int, len(root.cases))
+	for i, c := range root.cases {
+		caseRoot := c.Root().(*SelectCaseNode)
+		typ := int32(-1)
+		if !caseRoot.send {
+			typ = e.typ(core.VariableType)
+			if caseRoot.declaration != nil {
+				typ = e.typ(caseRoot.declaration.Typ)
+			}
+		}
+		descriptors[i] = e.emit(vm.OpSelectCase, slotOf(caseRoot.declaration), typ, 0, origin)
+	}
+	var ends []int
+	for i, c := range root.cases {
+		e.prototype.Code[descriptors[i]].C = int32(len(e.prototype.Code))
+		e.block(c.Root().(*SelectCaseNode).root, origin)
+		ends = append(ends, e.emit(vm.OpJump, 0, 0, 0, origin))
+	}
+	if root.hasOtherwise {
+		e.prototype.Code[selection].C = int32(len(e.prototype.Code))
+		e.block(root.otherwise, origin)
+	}
+	for _, end := range ends {
+		e.patch(end)
+	}
+}
+
+// function compiles the body of a function to a new prototype and returns its index.
+func (e *emitter) function(node *FunctionNode) int32 {
+	generics := []*core.Type{node.returnType}
+	for _, parameter := range node.parameters {
+		generics = append(generics, parameter.Typ)
+	}
+	prototype := &vm.Prototype{
+		Name:       node.name,
+		FrameSize:  node.frameSize,
+		Parameters: node.parameters,
+		Typ:        &core.Type{Name: "CustomFunction", Parent: builtin.FunctionType, Generic: true, Generics: generics},
+		ReturnType: node.returnType,
+	}
+	enclosing := e.prototype
+	e.prototype = prototype
+	e.block(node.entryNode, -1)
+	e.emit(vm.OpConstant, e.constant(nil), 0, 0, -1)
+	e.emit(vm.OpReturn, 0, 0, 0, -1)
+	e.prototype = enclosing
+	e.program.Prototypes = append(e.program.Prototypes, prototype)
+	return int32(len(e.program.Prototypes) - 1)
+}
@@ -5,20 +5,31 @@ import (
 	"fmt"
 	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
 	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/lexer"
 	"github.com/cevatbarisyilmaz/selinus/parser"
+	"math/big"
 	"strconv"
 )
 
 type VariableNode struct {
-	name string
+	name        string
+	declaration *core.Pointer
+	// unproven is set when the variable can not be proven to be assigned before it is read at compile time.
+	unproven bool
+	// depth and slot locate the variable in the frames, they are set by the resolver.
+	depth int
+	slot  int
 }
 
 func (node *VariableNode) Execute(scope *core.Scope) *core.Return {
-	scopeResult := scope.Get(node.name)
-	if scopeResult.ReturnType == core.EXCEPTION {
-		return scopeResult
+	pointer := node.declaration
+	if pointer.Slot != nil {
+		pointer = scope.Frame.Get(node.depth, node.slot)
+		if pointer == nil || (node.unproven && pointer.Variable == nil) {
+			return core.NewExceptionReturn("uninitialized variable " + node.name)
+		}
 	}
-	return &core.Return{ReturnType: core.NOTHING, Pointer: scopeResult.Pointer}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type StringNode struct {
@@ -38,11 +49,15 @@ func (node *BooleanNode) Execute(scope *core.Scope) *core.Return {
 }
 
 type FunctionNode struct {
-	name       string
-	lambda     bool
-	returnType *core.Type
-	parameters []*core.Parameter
-	entryNode  core.Node
+	name                  string
+	lambda                bool
+	returnType            *core.Type
+	parameters            []*core.Parameter
+	entryNode             core.Node
+	declaration           *core.Pointer
+	parameterDeclarations []*core.Pointer
+	// frameSize is the number of slots the function needs, it is set by the resolver.
+	frameSize int
 }
 
 func (node *FunctionNode) Execute(scope *core.Scope) *core.Return {
@@ -51,9 +66,9 @@ func (node *FunctionNode) Execute(scope *core.Scope) *core.Return {
 		generics = append(generics, parameter.Typ)
 	}
 	typ := &core.Type{Name: "CustomFunction", Parent: builtin.FunctionType, Generic: true, Generics: generics}
-	variable := core.NewVariable(&core.CustomFunction{Scope: scope.CloneWithName(node.name + "Function"), EntryNode: node.entryNode, Parameters: node.parameters, Typ: typ, ReturnType: node.returnType})
+	variable := core.NewVariable(&core.CustomFunction{Scope: scope, EntryNode: node.entryNode, Parameters: node.parameters, Typ: typ, ReturnType: node.returnType, FrameSize: node.frameSize})
 	if !node.lambda {
-		scope.DeclareAndSet(node.name, &core.Pointer{Typ: typ, Variable: variable})
+		scope.Frame.Slots[node.declaration.Slot.Index] = &core.Pointer{Typ: typ, Variable: variable, Immutable: true}
 	}
 	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: typ, Variable: variable}}
 }
@@ -66,6 +81,34 @@ func (node *IntegerNode) Execute(scope *core.Scope) *core.Return {
 	return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewIntegerPointer(node.value)}
 }
 
+type BigIntegerNode struct {
+	value *big.Int
+}
+
+func (node *BigIntegerNode) Execute(scope *core.Scope) *core.Return {
+	return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewBigIntegerPointer(node.value)}
+}
+
+type ConversionNode struct {
+	node core.Node
+	typ  *core.Type
+}
+
+func (node *ConversionNode) Execute(scope *core.Scope) *core.Return {
+	r := node.node.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	c := builtin.Convert(r.Pointer, node.typ)
+	if c.ReturnType != core.NOTHING {
+		return c
+	}
+	if exception := builtin.Charge(scope.Thread, c.Pointer, r.Pointer); exception != nil {
+		return exception
+	}
+	return c
+}
+
 type SetNode struct {
 	leftSide  core.Node
 	rightSide core.Node
@@ -80,24 +123,29 @@ func (node *SetNode) Execute(scope *core.Scope) *core.Return {
 	if l.ReturnType != core.NOTHING {
 		return l
 	}
+	if r.Pointer == nil {
+		return core.NewExceptionReturn("right side does not return a variable")
+	}
 	l.Pointer.Variable = r.Pointer.Variable
 	return &core.Return{ReturnType: core.NOTHING, Pointer: r.Pointer}
 }
 
 type DeclarationNode struct {
-	typ        *core.Type
-	identifier string
+	typ         *core.Type
+	identifier  string
+	declaration *core.Pointer
 }
 
 func (node *DeclarationNode) Execute(scope *core.Scope) *core.Return {
 	p := &core.Pointer{Typ: node.typ, Variable: nil}
-	scope.DeclareAndSet(node.identifier, p)
+	scope.Frame.Slots[node.declaration.Slot.Index] = p
 	return &core.Return{ReturnType: core.NOTHING, Pointer: p}
 }
 
 type ConditionNode struct {
 	condition core.Node
 	root      core.Node
+	otherwise core.Node
 }
 
 func (node *ConditionNode) Execute(scope *core.Scope) *core.Return {
@@ -105,58 +153,63 @@ func (node *ConditionNode) Execute(scope *core.Scope) *core.Return {
 	if internalReturn.ReturnType != core.NOTHING {
 		return internalReturn
 	}
-	if (internalReturn.Pointer.Variable).VariableInterface.(*builtin.Boolean).Value {
-		scope.CreateBlock()
-		defer scope.ReleaseBlock()
-		current := node.root
-		for current != nil {
-			internalReturn = current.Execute(scope)
-			if internalReturn.ReturnType != core.NOTHING {
-				return internalReturn
-			}
-			current = current.Next()
+	condition, exception := builtin.BooleanOf(internalReturn.Pointer)
+	if exception != nil {
+		return exception
+	}
+	current := node.otherwise
+	if condition {
+		current = node.root
+	}
+	for current != nil {
+		internalReturn = current.Execute(scope)
+		if internalReturn.ReturnType != core.NOTHING {
+			return internalReturn
 		}
+		current = current.Next()
 	}
 	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
 }
 
 type ToLoopNode struct {
-	from core.Node
-	to   core.Node
-	as   string
-	root core.Node
+	from        core.Node
+	to          core.Node
+	as          string
+	root        core.Node
+	declaration *core.Pointer
 }
 
 func (node *ToLoopNode) Execute(scope *core.Scope) *core.Return {
-	scope.CreateBlock()
-	defer scope.ReleaseBlock()
 	fromReturn := node.from.Execute(scope)
 	if fromReturn.ReturnType != core.NOTHING {
 		return fromReturn
 	}
-	fromReturnInteger := fromReturn.Pointer.Variable.ConvertTo(builtin.IntegerType)
-	if fromReturnInteger.ReturnType != core.NOTHING {
-		return fromReturn
+	from, exception := builtin.ToInteger(fromReturn.Pointer)
+	if exception != nil {
+		return exception
 	}
-	from := fromReturnInteger.Pointer.Variable.VariableInterface.(*builtin.Integer).Value
 
 	toReturn := node.to.Execute(scope)
 	if toReturn.ReturnType != core.NOTHING {
 		return toReturn
 	}
-	toReturnInteger := toReturn.Pointer.Variable.ConvertTo(builtin.IntegerType)
-	if toReturnInteger.ReturnType != core.NOTHING {
-		return toReturnInteger
+	to, exception := builtin.ToInteger(toReturn.Pointer)
+	if exception != nil {
+		return exception
 	}
-	to := toReturnInteger.Pointer.Variable.VariableInterface.(*builtin.Integer).Value
 
-	if node.as != "" {
-		scope.Declare(node.as, builtin.IntegerType)
+	var as *core.Pointer
+	if node.declaration != nil {
+		as = &core.Pointer{Typ: builtin.IntegerType}
+		scope.Frame.Slots[node.declaration.Slot.Index] = as
 	}
 
 	for i := from; i <= to; i++ {
-		if node.as != "" {
-			scope.Set(node.as, builtin.NewIntegerPointer(i))
+		if exception := scope.Thread.Step(); exception != nil {
+			return exception
+		}
+		if as != nil {
+			as.Variable = core.NewVariable(&builtin.Integer{Value: i})
 		}
 		current := node.root
 		for current != nil {
@@ -182,16 +235,21 @@ type ConditionLoopNode struct {
 }
 
 func (node *ConditionLoopNode) Execute(scope *core.Scope) *core.Return {
-	scope.CreateBlock()
-	defer scope.ReleaseBlock()
 	for {
 		internalReturn := node.condition.Execute(scope)
 		if internalReturn.ReturnType != core.NOTHING {
 			return internalReturn
 		}
-		if !(internalReturn.Pointer.Variable).VariableInterface.(*builtin.Boolean).Value {
+		condition, exception := builtin.BooleanOf(internalReturn.Pointer)
+		if exception != nil {
+			return exception
+		}
+		if !condition {
 			break
 		}
+		if exception := scope.Thread.Step(); exception != nil {
+			return exception
+		}
 		current := node.root
 		for current != nil {
 			internalReturn = current.Execute(scope)
@@ -223,10 +281,11 @@ func (node *CsvNode) Execute(scope *core.Scope) *core.Return {
 		}
 		children = append(children, subResult.Pointer)
 	}
-	return &core.Return{
-		ReturnType: core.NOTHING,
-		Pointer:    core.NewSetPointer(children),
+	set := core.NewSetPointer(children)
+	if exception := builtin.Charge(scope.Thread, set, nil); exception != nil {
+		return exception
 	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: set}
 }
 
 type OrNode struct {
@@ -239,20 +298,50 @@ func (node *OrNode) Execute(scope *core.Scope) *core.Return {
 	if l.ReturnType != core.NOTHING {
 		return l
 	}
-	ll := l.Pointer.Variable.ConvertTo(builtin.BooleanType)
-	if ll.ReturnType != core.NOTHING {
-		return ll
+	ll, exception := builtin.ToBoolean(l.Pointer)
+	if exception != nil {
+		return exception
+	}
+	if ll {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewBooleanPointer(true)}
 	}
 	r := node.right.Execute(scope)
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	rr := r.Pointer.Variable.ConvertTo(builtin.BooleanType)
-	if rr.ReturnType != core.NOTHING {
+	rr, exception := builtin.ToBoolean(r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewBooleanPointer(rr)}
+}
+
+type AndNode struct {
+	left  core.Node
+	right core.Node
+}
+
+func (node *AndNode) Execute(scope *core.Scope) *core.Return {
+	l := node.left.Execute(scope)
+	if l.ReturnType != core.NOTHING {
+		return l
+	}
+	ll, exception := builtin.ToBoolean(l.Pointer)
+	if exception != nil {
+		return exception
+	}
+	if !ll {
+		return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewBooleanPointer(false)}
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Boolean{Value: ll.Pointer.Variable.VariableInterface.(*builtin.Boolean).Value || r.Pointer.Variable.VariableInterface.(*builtin.Boolean).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.BooleanType, Variable: variable}}
+	rr, exception := builtin.ToBoolean(r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: builtin.NewBooleanPointer(rr)}
 }
 
 type SummationNode struct {
@@ -269,8 +358,11 @@ func (node *SummationNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Integer{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value + (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.IntegerType, Variable: variable}}
+	pointer, exception := builtin.Arithmetic(builtin.Addition, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type SubtractionNode struct {
@@ -295,8 +387,32 @@ func (node *SubtractionNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Integer{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value - (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.IntegerType, Variable: variable}}
+	pointer, exception := builtin.Arithmetic(builtin.Subtraction, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type MultiplicationNode struct {
+	left  core.Node
+	right core.Node
+}
+
+func (node *MultiplicationNode) Execute(scope *core.Scope) *core.Return {
+	l := node.left.Execute(scope)
+	if l.ReturnType != core.NOTHING {
+		return l
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	pointer, exception := builtin.Arithmetic(builtin.Multiplication, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type DivisionNode struct {
@@ -313,12 +429,11 @@ func (node *DivisionNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	divider := (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value
-	if divider == 0 {
-		return core.NewExceptionReturn("division by zero")
+	pointer, exception := builtin.Arithmetic(builtin.Division, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
 	}
-	variable := core.NewVariable(&builtin.Integer{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value / divider})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.IntegerType, Variable: variable}}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type EqualityNode struct {
@@ -335,8 +450,32 @@ func (node *EqualityNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Boolean{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value == (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.BooleanType, Variable: variable}}
+	pointer, exception := builtin.Comparison(builtin.Equal, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type InequalityNode struct {
+	left  core.Node
+	right core.Node
+}
+
+func (node *InequalityNode) Execute(scope *core.Scope) *core.Return {
+	l := node.left.Execute(scope)
+	if l.ReturnType != core.NOTHING {
+		return l
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	pointer, exception := builtin.Comparison(builtin.NotEqual, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type GreaterNode struct {
@@ -353,8 +492,11 @@ func (node *GreaterNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Boolean{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value > (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.BooleanType, Variable: variable}}
+	pointer, exception := builtin.Comparison(builtin.Greater, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type LessNode struct {
@@ -371,8 +513,100 @@ func (node *LessNode) Execute(scope *core.Scope) *core.Return {
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	variable := core.NewVariable(&builtin.Boolean{Value: (l.Pointer.Variable).VariableInterface.(*builtin.Integer).Value < (r.Pointer.Variable).VariableInterface.(*builtin.Integer).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.BooleanType, Variable: variable}}
+	pointer, exception := builtin.Comparison(builtin.Less, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type GreaterOrEqualNode struct {
+	left  core.Node
+	right core.Node
+}
+
+func (node *GreaterOrEqualNode) Execute(scope *core.Scope) *core.Return {
+	l := node.left.Execute(scope)
+	if l.ReturnType != core.NOTHING {
+		return l
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	pointer, exception := builtin.Comparison(builtin.GreaterOrEqual, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type LessOrEqualNode struct {
+	left  core.Node
+	right core.Node
+}
+
+func (node *LessOrEqualNode) Execute(scope *core.Scope) *core.Return {
+	l := node.left.Execute(scope)
+	if l.ReturnType != core.NOTHING {
+		return l
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	pointer, exception := builtin.Comparison(builtin.LessOrEqual, l.Pointer, r.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type BigIntegerArithmeticNode struct {
+	operator builtin.Operator
+	left     core.Node
+	right    core.Node
+}
+
+func (node *BigIntegerArithmeticNode) Execute(scope *core.Scope) *core.Return {
+	var l *core.Return
+	if node.left != nil {
+		l = node.left.Execute(scope)
+		if l.ReturnType != core.NOTHING {
+			return l
+		}
+	} else {
+		l = &core.Return{
+			ReturnType: core.NOTHING,
+			Pointer:    builtin.NewBigIntegerPointer(new(big.Int)),
+		}
+	}
+	lb := builtin.Convert(l.Pointer, builtin.BigIntegerType)
+	if lb.ReturnType != core.NOTHING {
+		return lb
+	}
+	if exception := builtin.Charge(scope.Thread, lb.Pointer, l.Pointer); exception != nil {
+		return exception
+	}
+	r := node.right.Execute(scope)
+	if r.ReturnType != core.NOTHING {
+		return r
+	}
+	rb := builtin.Convert(r.Pointer, builtin.BigIntegerType)
+	if rb.ReturnType != core.NOTHING {
+		return rb
+	}
+	if exception := builtin.Charge(scope.Thread, rb.Pointer, r.Pointer); exception != nil {
+		return exception
+	}
+	pointer, exception := builtin.BigIntegerArithmetic(node.operator, lb.Pointer, rb.Pointer)
+	if exception != nil {
+		return exception
+	}
+	if exception := builtin.Charge(scope.Thread, pointer, nil); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type ConcatenationNode struct {
@@ -385,53 +619,78 @@ func (node *ConcatenationNode) Execute(scope *core.Scope) *core.Return {
 	if l.ReturnType != core.NOTHING {
 		return l
 	}
-	ls := l.Pointer.Variable.ConvertTo(builtin.StringType)
+	ls := builtin.Convert(l.Pointer, builtin.StringType)
 	if ls.ReturnType != core.NOTHING {
 		return ls
 	}
+	if exception := builtin.Charge(scope.Thread, ls.Pointer, l.Pointer); exception != nil {
+		return exception
+	}
 	r := node.right.Execute(scope)
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	rs := r.Pointer.Variable.ConvertTo(builtin.StringType)
+	rs := builtin.Convert(r.Pointer, builtin.StringType)
 	if rs.ReturnType != core.NOTHING {
 		return rs
 	}
-	variable := core.NewVariable(&builtin.String{Value: ls.Pointer.Variable.VariableInterface.(*builtin.String).Value + rs.Pointer.Variable.VariableInterface.(*builtin.String).Value})
-	return &core.Return{ReturnType: core.NOTHING, Pointer: &core.Pointer{Typ: builtin.StringType, Variable: variable}}
+	if exception := builtin.Charge(scope.Thread, rs.Pointer, r.Pointer); exception != nil {
+		return exception
+	}
+	pointer, exception := builtin.Concatenate(ls.Pointer, rs.Pointer)
+	if exception != nil {
+		return exception
+	}
+	if exception := builtin.Charge(scope.Thread, pointer, nil); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
 }
 
 type FunctionCallNode struct {
-	name       string
-	parameters []core.Node
+	name        string
+	parameters  []core.Node
+	declaration *core.Pointer
+	// depth and slot locate the function in the frames, they are set by the resolver.
+	depth int
+	slot  int
+	// tail is set by the optimizer for calls whose result is returned right away by the calling function.
+	tail bool
 }
 
 func (node *FunctionCallNode) Execute(localScope *core.Scope) *core.Return {
-	scopeResult := localScope.Get(node.name)
-	if scopeResult.ReturnType == core.EXCEPTION {
-		return scopeResult
-	}
-	b := scopeResult.Pointer.Variable
-	function := b.VariableInterface.(core.Function)
-	functionScope := function.GetScope().Clone()
-	functionScope.CreateBlock()
-	defer functionScope.ReleaseBlock()
-	i := 0
-	for _, e := range node.parameters {
-		t := e.Execute(localScope)
-		if t.ReturnType != core.NOTHING {
-			return t
+	function, arguments, exception := node.prepare(localScope)
+	if exception != nil {
+		return exception
+	}
+	if node.tail {
+		if exception := localScope.Thread.Step(); exception != nil {
+			return exception
 		}
-		functionScope.Declare(function.GetParameters()[i].Name, function.GetParameters()[i].Typ)
-		functionScope.Set(function.GetParameters()[i].Name, t.Pointer)
-		i++
+		return &core.Return{ReturnType: core.CALL, Call: &core.TailCall{Function: function, Arguments: arguments, Name: node.name}}
 	}
-	for x := len(function.GetParameters()); i < x; i++ {
-		functionScope.Declare(function.GetParameters()[i].Name, function.GetParameters()[i].Typ)
-		functionScope.Set(function.GetParameters()[i].Name, function.GetParameters()[i].DefaultValue)
+	return core.Call(localScope.Thread, function, arguments, node.name)
+}
+
+// prepare looks up the function and evaluates the arguments of the call.
+func (node *FunctionCallNode) prepare(localScope *core.Scope) (core.Function, []*core.Pointer, *core.Return) {
+	pointer := node.declaration
+	if pointer.Slot != nil {
+		pointer = localScope.Frame.Get(node.depth, node.slot)
+	}
+	function, exception := core.FunctionOf(pointer, node.name)
+	if exception != nil {
+		return nil, nil, exception
+	}
+	arguments := make([]*core.Pointer, len(node.parameters))
+	for i, parameter := range node.parameters {
+		t := parameter.Execute(localScope)
+		if t.ReturnType != core.NOTHING {
+			return nil, nil, t
+		}
+		arguments[i] = t.Pointer
 	}
-	res := function.Execute(functionScope)
-	return res
+	return function, arguments, nil
 }
 
 type ReturnNode struct {
@@ -446,14 +705,74 @@ func (node *ReturnNode) Execute(scope *core.Scope) *core.Return {
 	return &core.Return{ReturnType: core.RETURN, Pointer: internalReturn.Pointer}
 }
 
+// ResultNode ends a program with the value of its last statement, see CompileResult. It runs the root of the statement
+// rather than the statement itself, since the node of the result adds the position of the statement to exceptions
+// already.
+type ResultNode struct {
+	node core.Node
+}
+
+func (node *ResultNode) Execute(scope *core.Scope) *core.Return {
+	internalReturn := node.node.Root().Execute(scope)
+	if internalReturn.ReturnType != core.NOTHING {
+		return internalReturn
+	}
+	return &core.Return{ReturnType: core.RETURN, Pointer: internalReturn.Pointer}
+}
+
 func Compile(node *parser.ParseNode, scope *core.Scope) (core.Node, error) {
-	return parseBlock(node, scope, nil)
+	root, _, err := compile(node, scope, false)
+	return root, err
+}
+
+// CompileResult compiles a program like Compile. When the last statement of the program is an expression that has a
+// value, the program returns the value when it runs and the type of the expression is returned too.
+func CompileResult(node *parser.ParseNode, scope *core.Scope) (core.Node, *core.Type, error) {
+	return compile(node, scope, true)
+}
+
+func compile(node *parser.ParseNode, scope *core.Scope, result bool) (core.Node, *core.Type, error) {
+	var root core.Node
+	var prev core.Node
+	var typ *core.Type
+	for node != nil {
+		current, t, err := createNode(node, scope, false, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result && node.Next() == nil && t != nil && isExpression(node) {
+			current = core.NewNode(&ResultNode{node: current}, current.Position())
+			typ = t
+		}
+		if root == nil {
+			root = current
+		} else {
+			prev.SetNext(current)
+		}
+		prev = current
+		node = node.Next()
+	}
+	err := checkAssignments(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	root = optimize(root, scope)
+	resolve(root, scope)
+	return root, typ, nil
+}
+
+// isExpression reports whether the statement is evaluated for its value rather than to declare or assign a name.
+func isExpression(node *parser.ParseNode) bool {
+	switch node.GetType() {
+	case parser.Function, parser.Declaration, parser.Gets, parser.Constant, parser.Let:
+		return false
+	}
+	return true
 }
 
 func parseBlock(node *parser.ParseNode, scope *core.Scope, expectedType *core.Type) (core.Node, error) {
 	var root core.Node
 	var prev core.Node
-	var lastNode *parser.ParseNode
 	for node != nil {
 		current, _, err := createNode(node, scope, false, expectedType)
 		if err != nil {
@@ -465,16 +784,33 @@ func parseBlock(node *parser.ParseNode, scope *core.Scope, expectedType *core.Ty
 			prev.SetNext(current)
 		}
 		prev = current
-		lastNode = node
 		node = node.Next()
 	}
-	if expectedType != nil {
-		_, b := prev.Root().(*ReturnNode)
-		if !b {
-			return nil, errors.New("expected return statement " + lastNode.GetMainToken().ToString())
+	return root, nil
+}
+
+// returns reports whether every path through the block ends with a return statement.
+func returns(block core.Node) bool {
+	if block == nil {
+		return false
+	}
+	for block.Next() != nil {
+		block = block.Next()
+	}
+	switch root := block.Root().(type) {
+	case *ReturnNode:
+		return true
+	case *ConditionNode:
+		return returns(root.root) && returns(root.otherwise)
+	case *SelectNode:
+		for _, c := range root.cases {
+			if !returns(c.Root().(*SelectCaseNode).root) {
+				return false
+			}
 		}
+		return !root.hasOtherwise || returns(root.otherwise)
 	}
-	return root, nil
+	return false
 }
 
 func createNode(node *parser.ParseNode, scope *core.Scope, conditional bool, expectedReturnType *core.Type) (core.Node, *core.Type, error) {
@@ -488,21 +824,7 @@ func createNode(node *parser.ParseNode, scope *core.Scope, conditional bool, exp
 func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool, expectedReturnType *core.Type) (core.NodeRoot, *core.Type, error) {
 	switch node.GetType() {
 	case parser.Less:
-		l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		r, rt, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		if !lt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation < " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
-		}
-		if !rt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation < " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
-		}
-		return &LessNode{left: l, right: r}, builtin.BooleanType, nil
+		return createComparisonNodeRoot(node, scope, lexer.Less)
 	case parser.Csv:
 		var children []core.Node
 		var childrenNodeType []*core.Type
@@ -524,10 +846,23 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if err != nil {
 			return nil, nil, err
 		}
-		if lt.IsConvertable(builtin.BooleanType) && rt.IsConvertable(builtin.BooleanType) {
+		if lt != nil && rt != nil && lt.IsConvertable(builtin.BooleanType) && rt.IsConvertable(builtin.BooleanType) {
 			return &OrNode{left: l, right: r}, builtin.BooleanType, nil
 		}
 		return nil, nil, errors.New("incompatible types for operation  " + node.MainLexicalToken.ToString())
+	case parser.And:
+		l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, rt, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lt != nil && rt != nil && lt.IsConvertable(builtin.BooleanType) && rt.IsConvertable(builtin.BooleanType) {
+			return &AndNode{left: l, right: r}, builtin.BooleanType, nil
+		}
+		return nil, nil, errors.New("incompatible types for operation  " + node.MainLexicalToken.ToString())
 	case parser.Summation:
 		l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
 		if err != nil {
@@ -537,10 +872,16 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if err != nil {
 			return nil, nil, err
 		}
-		if lt.IsConvertable(builtin.IntegerType) && rt.IsConvertable(builtin.IntegerType) {
+		if lt == nil || rt == nil {
+			return nil, nil, errors.New("operand does not return a variable for operation " + node.GetMainToken().ToString())
+		}
+		if isBigIntegerOperation(lt, rt) {
+			return &BigIntegerArithmeticNode{operator: builtin.Addition, left: l, right: r}, builtin.BigIntegerType, nil
+		}
+		if lt == builtin.IntegerType && rt == builtin.IntegerType {
 			return &SummationNode{left: l, right: r}, builtin.IntegerType, nil
 		}
-		if lt.IsConvertable(builtin.StringType) && rt.IsConvertable(builtin.StringType) {
+		if (lt == builtin.StringType || rt == builtin.StringType) && lt.IsConvertable(builtin.StringType) && rt.IsConvertable(builtin.StringType) {
 			return &ConcatenationNode{left: l, right: r}, builtin.StringType, nil
 		}
 		return nil, nil, errors.New("incompatible types " + lt.Name + " and " + rt.Name + " for operation  " + node.MainLexicalToken.ToString())
@@ -561,6 +902,9 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if err != nil {
 			return nil, nil, err
 		}
+		if isBigIntegerOperation(lt, rt) {
+			return &BigIntegerArithmeticNode{operator: builtin.Subtraction, left: l, right: r}, builtin.BigIntegerType, nil
+		}
 		if !rt.IsCompatible(builtin.IntegerType) {
 			return nil, nil, errors.New("incompatible type for operation - " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
 		}
@@ -574,6 +918,9 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if err != nil {
 			return nil, nil, err
 		}
+		if isBigIntegerOperation(lt, rt) {
+			return &BigIntegerArithmeticNode{operator: builtin.Division, left: l, right: r}, builtin.BigIntegerType, nil
+		}
 		if !lt.IsCompatible(builtin.IntegerType) {
 			return nil, nil, errors.New("incompatible type for operation / " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
 		}
@@ -582,22 +929,10 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		}
 		return &DivisionNode{left: l, right: r}, builtin.IntegerType, nil
 	case parser.Equal:
-		l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		r, rt, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		if !lt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation == " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
-		}
-		if !rt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation == " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
-		}
-		return &EqualityNode{left: l, right: r}, builtin.BooleanType, nil
+		return createComparisonNodeRoot(node, scope, lexer.Equal)
 	case parser.Greater:
+		return createComparisonNodeRoot(node, scope, lexer.Greater)
+	case parser.Multiply:
 		l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
 		if err != nil {
 			return nil, nil, err
@@ -606,31 +941,62 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if err != nil {
 			return nil, nil, err
 		}
+		if isBigIntegerOperation(lt, rt) {
+			return &BigIntegerArithmeticNode{operator: builtin.Multiplication, left: l, right: r}, builtin.BigIntegerType, nil
+		}
 		if !lt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation > " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
+			return nil, nil, errors.New("incompatible type for operation * " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
 		}
 		if !rt.IsCompatible(builtin.IntegerType) {
-			return nil, nil, errors.New("incompatible type for operation > " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
-		}
-		return &GreaterNode{left: l, right: r}, builtin.BooleanType, nil
+			return nil, nil, errors.New("incompatible type for operation * " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
+		}
+		return &MultiplicationNode{left: l, right: r}, builtin.IntegerType, nil
+	case parser.NotEqual:
+		return createComparisonNodeRoot(node, scope, lexer.NotEqual)
+	case parser.GreaterOrEqual:
+		return createComparisonNodeRoot(node, scope, lexer.GreaterOrEqual)
+	case parser.LessOrEqual:
+		return createComparisonNodeRoot(node, scope, lexer.LessOrEqual)
 	case parser.Variable:
 		res := scope.Get(node.GetMainToken().GetValue())
 		if res.ReturnType != core.NOTHING {
 			return nil, nil, errors.New(node.GetMainToken().GetValue() + " is not declared " + node.GetMainToken().ToString())
 		}
-		return &VariableNode{name: node.GetMainToken().GetValue()}, res.Pointer.Typ, nil
+		if builtin.IsIntrinsic(res.Pointer) {
+			return nil, nil, errors.New(node.GetMainToken().GetValue() + " can only be called " + node.GetMainToken().ToString())
+		}
+		if res.Pointer.Constant {
+			return &ConstantNode{pointer: res.Pointer}, res.Pointer.Typ, nil
+		}
+		return &VariableNode{name: node.GetMainToken().GetValue(), declaration: res.Pointer}, res.Pointer.Typ, nil
 	case parser.String:
 		return &StringNode{value: node.GetMainToken().GetValue()}, builtin.StringType, nil
 	case parser.Integer:
-		i, _ := strconv.ParseInt(node.GetMainToken().GetValue(), 10, 64)
+		i, err := strconv.ParseInt(node.GetMainToken().GetValue(), 10, 64)
+		if err != nil {
+			return nil, nil, errors.New("integer literal out of range, use the " + string(lexer.BigIntegerSuffix) + " suffix for big integers " + node.GetMainToken().ToString())
+		}
 		return &IntegerNode{value: i}, builtin.IntegerType, nil
+	case parser.BigInteger:
+		i, ok := new(big.Int).SetString(node.GetMainToken().GetValue(), 10)
+		if !ok {
+			return nil, nil, errors.New("invalid big integer literal " + node.GetMainToken().ToString())
+		}
+		return &BigIntegerNode{value: i}, builtin.BigIntegerType, nil
 	case parser.FunctionCall:
 		t := scope.MustGet(node.GetMainToken().GetValue())
 		if t == nil {
 			return nil, nil, errors.New("function " + node.GetMainToken().GetValue() + " is not defined " + node.GetMainToken().ToString())
+		} else if builtin.IsIntrinsic(t) {
+			return createIntrinsicNodeRoot(node, scope, t)
+		} else if t.Typ == core.TypeType {
+			return createConversionNodeRoot(node, scope, t.Variable.VariableInterface.(*core.TypeVariable).Value)
 		} else if !t.Typ.IsCompatible(builtin.FunctionType) {
 			return nil, nil, errors.New(node.GetMainToken().GetValue() + " is not a function " + node.GetMainToken().ToString())
 		}
+		if !t.Typ.Generic {
+			return nil, nil, errors.New("signature of " + node.GetMainToken().GetValue() + " is unknown " + node.GetMainToken().ToString())
+		}
 		types := t.Typ.Generics
 		parameters := types[1:]
 		returnType := types[0]
@@ -650,9 +1016,10 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 				if err != nil {
 					return nil, nil, err
 				}
-				if !typ.IsCompatible(parameters[i]) {
+				if !isAssignable(typ, parameters[i]) {
 					return nil, nil, errors.New("incompatible parameter type " + parameter.GetMainToken().ToString())
 				}
+				g, _ = promote(g, typ, parameters[i])
 				suppliedParameters = append(suppliedParameters, g)
 				i++
 			}
@@ -666,37 +1033,22 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 				//}
 			}
 		*/
-		return &FunctionCallNode{name: node.GetMainToken().GetValue(), parameters: suppliedParameters}, returnType, nil
+		return &FunctionCallNode{name: node.GetMainToken().GetValue(), parameters: suppliedParameters, declaration: t}, returnType, nil
 	case parser.Declaration:
-		switch node.GetMainToken().GetValue() {
-		case "var":
-			scope.Declare(node.GetTokenWithKey(parser.Identifier).GetValue(), core.VariableType)
-			return &DeclarationNode{typ: core.VariableType, identifier: node.GetTokenWithKey(parser.Identifier).GetValue()}, core.VariableType, nil
-		case "string":
-			scope.Declare(node.GetTokenWithKey(parser.Identifier).GetValue(), builtin.StringType)
-			return &DeclarationNode{typ: builtin.StringType, identifier: node.GetTokenWithKey(parser.Identifier).GetValue()}, builtin.StringType, nil
-		case "int":
-			scope.Declare(node.GetTokenWithKey(parser.Identifier).GetValue(), builtin.IntegerType)
-			return &DeclarationNode{typ: builtin.IntegerType, identifier: node.GetTokenWithKey(parser.Identifier).GetValue()}, builtin.IntegerType, nil
-		}
-		return nil, nil, errors.New("unknown declaration type " + node.GetMainToken().ToString())
+		typ, err := resolveDeclarationType(node.GetMainToken(), scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		existing := scope.MustGetFromCurrentBlock(node.GetTokenWithKey(parser.Identifier).GetValue())
+		if existing != nil && existing.Immutable {
+			return nil, nil, errors.New(node.GetTokenWithKey(parser.Identifier).GetValue() + " is already declared " + node.GetTokenWithKey(parser.Identifier).ToString())
+		}
+		scope.Declare(node.GetTokenWithKey(parser.Identifier).GetValue(), typ)
+		declaration := scope.MustGetFromCurrentBlock(node.GetTokenWithKey(parser.Identifier).GetValue())
+		return &DeclarationNode{typ: typ, identifier: node.GetTokenWithKey(parser.Identifier).GetValue(), declaration: declaration}, typ, nil
 	case parser.Gets:
 		if conditional {
-			l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
-			if err != nil {
-				return nil, nil, err
-			}
-			r, rt, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
-			if err != nil {
-				return nil, nil, err
-			}
-			if lt != builtin.IntegerType {
-				return nil, nil, errors.New("incompatible type for operation = " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
-			}
-			if rt != builtin.IntegerType {
-				return nil, nil, errors.New("incompatible type for operation = " + node.GetParseNodesWithKey(parser.Children)[1].GetMainToken().ToString())
-			}
-			return &EqualityNode{left: l, right: r}, builtin.BooleanType, nil
+			return createComparisonNodeRoot(node, scope, lexer.Equal)
 		}
 		r, t2, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
 		if err != nil {
@@ -709,9 +1061,10 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if t2 == nil {
 			return nil, nil, errors.New("right side does not return a variable " + node.GetMainToken().ToString())
 		}
-		if !t2.IsCompatible(t1) {
+		if !isAssignable(t2, t1) {
 			return nil, nil, errors.New("incompatible types " + node.GetMainToken().ToString())
 		}
+		r, t2 = promote(r, t2, t1)
 		return &SetNode{rightSide: r, leftSide: l}, t2, nil
 	case parser.If:
 		condition, t1, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, true, nil)
@@ -721,11 +1074,22 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if t1 != builtin.BooleanType {
 			return nil, nil, errors.New("expected boolean " + node.GetParseNodesWithKey(parser.Children)[0].GetMainToken().ToString())
 		}
+		scope.CreateBlock()
 		root, err := parseBlock(node.GetParseNodesWithKey(parser.Children)[1], scope, expectedReturnType)
+		scope.ReleaseBlock()
 		if err != nil {
 			return nil, nil, err
 		}
-		return &ConditionNode{condition: condition, root: root}, nil, nil
+		var otherwise core.Node
+		if len(node.GetParseNodesWithKey(parser.Otherwise)) > 0 {
+			scope.CreateBlock()
+			otherwise, err = parseBlock(node.GetParseNodesWithKey(parser.Otherwise)[0], scope, expectedReturnType)
+			scope.ReleaseBlock()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return &ConditionNode{condition: condition, root: root, otherwise: otherwise}, nil, nil
 	case parser.ToLoop:
 		fromNode, t1, err := createNode(node.GetParseNodesWithKey(parser.From)[0], scope, false, nil)
 		if err != nil {
@@ -744,19 +1108,32 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		scope.CreateBlock()
 		defer scope.ReleaseBlock()
 		as := node.GetTokenWithKey(parser.Identifier)
+		var declaration *core.Pointer
 		if as != nil {
 			scope.Declare(as.GetValue(), builtin.IntegerType)
+			declaration = scope.MustGetFromCurrentBlock(as.GetValue())
 		}
 		root, err := parseBlock(node.GetParseNodesWithKey(parser.Children)[0], scope, expectedReturnType)
 		if err != nil {
 			return nil, nil, err
 		}
 		return &ToLoopNode{
-			from: fromNode,
-			to:   toNode,
-			as:   node.GetTokenWithKey(parser.Identifier).GetValue(),
-			root: root,
+			from:        fromNode,
+			to:          toNode,
+			as:          node.GetTokenWithKey(parser.Identifier).GetValue(),
+			root:        root,
+			declaration: declaration,
 		}, nil, nil
+	case parser.Spawn:
+		return createSpawnNodeRoot(node, scope)
+	case parser.Join:
+		return &JoinNode{}, nil, nil
+	case parser.Select:
+		return createSelectNodeRoot(node, scope, expectedReturnType)
+	case parser.Constant:
+		return createConstantNodeRoot(node, scope)
+	case parser.Let:
+		return createLetNodeRoot(node, scope)
 	case parser.Boolean:
 		return &BooleanNode{value: node.GetMainToken().GetValue() == "true"}, builtin.BooleanType, nil
 	case parser.Function:
@@ -781,19 +1158,32 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		for _, parameter := range parameters {
 			generics = append(generics, parameter.Typ)
 		}
-		scope.Declare(node.GetTokenWithKey(parser.Identifier).GetValue(), &core.Type{Name: node.GetTokenWithKey(parser.Identifier).GetValue(), Parent: builtin.FunctionType, Generic: true, Generics: generics})
+		existing := scope.MustGetFromCurrentBlock(node.GetTokenWithKey(parser.Identifier).GetValue())
+		if existing != nil && existing.Immutable {
+			return nil, nil, errors.New(node.GetTokenWithKey(parser.Identifier).GetValue() + " is already declared " + node.GetTokenWithKey(parser.Identifier).ToString())
+		}
+		declaration := &core.Pointer{Typ: &core.Type{Name: node.GetTokenWithKey(parser.Identifier).GetValue(), Parent: builtin.FunctionType, Generic: true, Generics: generics}, Variable: nil, Immutable: true}
+		scope.DeclareAndSet(node.GetTokenWithKey(parser.Identifier).GetValue(), declaration)
 		scope.CreateBlock()
+		var parameterDeclarations []*core.Pointer
 		for _, parameter := range parameters {
 			scope.Declare(parameter.Name, parameter.Typ)
+			parameterDeclarations = append(parameterDeclarations, scope.MustGetFromCurrentBlock(parameter.Name))
 		}
 		root, err := parseBlock(node.GetParseNodesWithKey(parser.Children)[0], scope, returnType)
 		if err != nil {
 			return nil, nil, err
 		}
 		scope.ReleaseBlock()
-		return &FunctionNode{name: node.GetTokenWithKey(parser.Identifier).GetValue(), lambda: false, parameters: parameters, returnType: returnType, entryNode: root}, builtin.FunctionType, nil
+		if returnType != nil && !returns(root) {
+			return nil, nil, errors.New("expected return statement at the end of function " + node.GetTokenWithKey(parser.Identifier).ToString())
+		}
+		return &FunctionNode{name: node.GetTokenWithKey(parser.Identifier).GetValue(), lambda: false, parameters: parameters, returnType: returnType, entryNode: root, declaration: declaration, parameterDeclarations: parameterDeclarations}, builtin.FunctionType, nil
 	case parser.Return:
-		if len(node.GetParseNodesWithKey(parser.Children)) == 0 && expectedReturnType != nil {
+		if len(node.GetParseNodesWithKey(parser.Children)) == 0 {
+			if expectedReturnType == nil {
+				return nil, nil, errors.New("unexpected return statement " + node.GetMainToken().ToString())
+			}
 			return nil, nil, errors.New("expected expression after " + node.GetMainToken().ToString())
 		}
 		temp, typ, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, expectedReturnType)
@@ -803,33 +1193,118 @@ func createNodeRoot(node *parser.ParseNode, scope *core.Scope, conditional bool,
 		if expectedReturnType == nil {
 			return nil, nil, errors.New("unexpected return statement " + node.GetMainToken().ToString())
 		}
-		if !typ.IsCompatible(expectedReturnType) {
+		if !isAssignable(typ, expectedReturnType) {
 			return nil, nil, errors.New("unexpected return type for the function " + node.GetMainToken().ToString())
 		}
+		temp, typ = promote(temp, typ, expectedReturnType)
 		return &ReturnNode{node: temp}, typ, nil
 	}
 	return nil, nil, errors.New("unknown node type " + node.GetMainToken().ToString())
 }
 
+func createConversionNodeRoot(node *parser.ParseNode, scope *core.Scope, typ *core.Type) (core.NodeRoot, *core.Type, error) {
+	parameters := node.GetParseNodesWithKey(parser.Parameters)
+	if len(parameters) != 1 {
+		return nil, nil, errors.New(fmt.Sprintf("conversion to %s takes exactly one parameter %s", typ.Name, node.GetMainToken().ToString()))
+	}
+	g, gt, err := createNode(parameters[0], scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if gt == nil {
+		return nil, nil, errors.New("parameter does not return a variable " + parameters[0].GetMainToken().ToString())
+	}
+	if gt != core.VariableType && !gt.IsConvertable(typ) {
+		return nil, nil, errors.New("conversion from " + gt.Name + " to " + typ.Name + " is not possible " + node.GetMainToken().ToString())
+	}
+	return &ConversionNode{node: g, typ: typ}, typ, nil
+}
+
+func createComparisonNodeRoot(node *parser.ParseNode, scope *core.Scope, operator string) (core.NodeRoot, *core.Type, error) {
+	l, lt, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, rt, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lt == nil || rt == nil {
+		return nil, nil, errors.New("operand does not return a variable for operation " + node.GetMainToken().ToString())
+	}
+	if operator == lexer.Equal || operator == lexer.NotEqual {
+		if !builtin.IsEquatable(lt, rt) {
+			return nil, nil, errors.New("incompatible types " + lt.Name + " and " + rt.Name + " for operation " + node.GetMainToken().ToString())
+		}
+	} else if !builtin.IsOrdered(lt, rt) {
+		return nil, nil, errors.New("incompatible types " + lt.Name + " and " + rt.Name + " for operation " + node.GetMainToken().ToString())
+	}
+	l, _ = promote(l, lt, rt)
+	r, _ = promote(r, rt, lt)
+	switch operator {
+	case lexer.Equal:
+		return &EqualityNode{left: l, right: r}, builtin.BooleanType, nil
+	case lexer.NotEqual:
+		return &InequalityNode{left: l, right: r}, builtin.BooleanType, nil
+	case lexer.Greater:
+		return &GreaterNode{left: l, right: r}, builtin.BooleanType, nil
+	case lexer.GreaterOrEqual:
+		return &GreaterOrEqualNode{left: l, right: r}, builtin.BooleanType, nil
+	case lexer.Less:
+		return &LessNode{left: l, right: r}, builtin.BooleanType, nil
+	}
+	return &LessOrEqualNode{left: l, right: r}, builtin.BooleanType, nil
+}
+
+func isBigIntegerOperation(lt *core.Type, rt *core.Type) bool {
+	if lt != builtin.BigIntegerType && rt != builtin.BigIntegerType {
+		return false
+	}
+	return (lt == nil || lt == builtin.IntegerType || lt == builtin.BigIntegerType) && (rt == builtin.IntegerType || rt == builtin.BigIntegerType)
+}
+
+// isAssignable reports whether a value of type from can be stored where to is expected, including the integers that
+// promote widens to big integers.
+func isAssignable(from *core.Type, to *core.Type) bool {
+	return from.IsCompatible(to) || (from == builtin.IntegerType && to == builtin.BigIntegerType)
+}
+
+// promote wraps node in a conversion when an integer is used where a big integer is expected.
+func promote(node core.Node, from *core.Type, to *core.Type) (core.Node, *core.Type) {
+	if from == builtin.IntegerType && to == builtin.BigIntegerType {
+		return core.NewNode(&ConversionNode{node: node, typ: to}, node.Position()), to
+	}
+	return node, from
+}
+
 func createLeftSideForSet(node *parser.ParseNode, scope *core.Scope, typ *core.Type) (core.Node, *core.Type, error) {
 	if node.GetType() == parser.Variable && scope.Get(node.GetMainToken().GetValue()) == nil {
 		scope.Declare(node.GetMainToken().GetValue(), typ)
 	}
+	if node.GetType() == parser.Variable {
+		p := scope.MustGet(node.GetMainToken().GetValue())
+		if p != nil && p.Immutable {
+			return nil, nil, errors.New("cannot assign to immutable " + node.GetMainToken().ToString())
+		}
+	}
 	return createNode(node, scope, false, nil)
 }
 
 func parameterize(node *parser.ParseNode, scope *core.Scope) (*core.Parameter, error) {
-	var typ *core.Type
-	switch node.GetMainToken().GetValue() {
-	case "var":
-		typ = core.VariableType
-	case "string":
-		typ = builtin.StringType
-	case "int":
-		typ = builtin.IntegerType
-	default:
+	typ, err := resolveDeclarationType(node.GetMainToken(), scope)
+	if err != nil {
 		return nil, errors.New("unknown parameter type " + node.GetMainToken().ToString())
 	}
-	//scope.Declare(node.GetToken2().ToString(), typ)
 	return &core.Parameter{Typ: typ, Name: node.GetTokenWithKey(parser.Identifier).GetValue(), DefaultValue: nil}, nil
 }
+
+func resolveDeclarationType(token *lexer.LexicalToken, scope *core.Scope) (*core.Type, error) {
+	if token.GetValue() == "var" {
+		return core.VariableType, nil
+	}
+	t := scope.MustGet(token.GetValue())
+	if t == nil || t.Typ != core.TypeType {
+		return nil, errors.New("unknown declaration type " + token.ToString())
+	}
+	return t.Variable.VariableInterface.(*core.TypeVariable).Value, nil
+}
@@ -0,0 +1,339 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+)
+
+// SpawnNode calls a function in a new task, see core.Thread.Spawn. The function and its arguments are evaluated by the
+// spawning task.
+type SpawnNode struct {
+	call core.Node
+}
+
+func (node *SpawnNode) Execute(scope *core.Scope) *core.Return {
+	call := node.call.Root().(*FunctionCallNode)
+	function, arguments, exception := call.prepare(scope)
+	if exception != nil {
+		return core.AddPositionToStackTrace(exception, node.call.Position())
+	}
+	core.Spawn(scope.Thread, function, arguments, call.name, node.call.Position())
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+// JoinNode waits for the tasks spawned so far and raises the first exception they raised.
+type JoinNode struct{}
+
+func (node *JoinNode) Execute(scope *core.Scope) *core.Return {
+	if exception := scope.Thread.Join(); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+type ChannelNode struct {
+	element *core.Type
+	// size is the number of values the channel buffers, it is nil for unbuffered channels.
+	size core.Node
+}
+
+func (node *ChannelNode) Execute(scope *core.Scope) *core.Return {
+	var size int64
+	if node.size != nil {
+		r := node.size.Execute(scope)
+		if r.ReturnType != core.NOTHING {
+			return r
+		}
+		var exception *core.Return
+		size, exception = builtin.IntegerOf(r.Pointer)
+		if exception != nil {
+			return exception
+		}
+	}
+	pointer, exception := builtin.NewChannelPointer(node.element, size)
+	if exception != nil {
+		return exception
+	}
+	if exception := builtin.Charge(scope.Thread, pointer, nil); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type SendNode struct {
+	channel core.Node
+	value   core.Node
+}
+
+func (node *SendNode) Execute(scope *core.Scope) *core.Return {
+	c := node.channel.Execute(scope)
+	if c.ReturnType != core.NOTHING {
+		return c
+	}
+	v := node.value.Execute(scope)
+	if v.ReturnType != core.NOTHING {
+		return v
+	}
+	if exception := builtin.Send(scope.Thread, c.Pointer, v.Pointer); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+type ReceiveNode struct {
+	channel core.Node
+}
+
+func (node *ReceiveNode) Execute(scope *core.Scope) *core.Return {
+	c := node.channel.Execute(scope)
+	if c.ReturnType != core.NOTHING {
+		return c
+	}
+	pointer, exception := builtin.Receive(scope.Thread, c.Pointer)
+	if exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer}
+}
+
+type CloseNode struct {
+	channel core.Node
+}
+
+func (node *CloseNode) Execute(scope *core.Scope) *core.Return {
+	c := node.channel.Execute(scope)
+	if c.ReturnType != core.NOTHING {
+		return c
+	}
+	if exception := builtin.Close(scope.Thread, c.Pointer); exception != nil {
+		return exception
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+// SelectNode performs one of its cases that can proceed, chosen at random when several can, and runs its body. Without
+// an else case it waits until one can, with one it runs the else case instead.
+type SelectNode struct {
+	cases        []core.Node
+	otherwise    core.Node
+	hasOtherwise bool
+}
+
+// SelectCaseNode is a case of a select. It sends value on channel when send is set and receives from channel otherwise,
+// storing the received value in a new variable when declaration is not nil.
+type SelectCaseNode struct {
+	channel     core.Node
+	value       core.Node
+	send        bool
+	declaration *core.Pointer
+	root        core.Node
+}
+
+// Execute is not called, the cases are performed by the select they belong to.
+func (node *SelectCaseNode) Execute(scope *core.Scope) *core.Return {
+	panic("select case executed outside of a select")
+}
+
+func (node *SelectNode) Execute(scope *core.Scope) *core.Return {
+	cases := make([]builtin.SelectCase, len(node.cases))
+	for i, c := range node.cases {
+		root := c.Root().(*SelectCaseNode)
+		r := root.channel.Execute(scope)
+		if r.ReturnType != core.NOTHING {
+			return core.AddPositionToStackTrace(r, c.Position())
+		}
+		cases[i].Channel = r.Pointer
+		if root.send {
+			r = root.value.Execute(scope)
+			if r.ReturnType != core.NOTHING {
+				return core.AddPositionToStackTrace(r, c.Position())
+			}
+			cases[i].Value = r.Pointer
+		}
+	}
+	chosen, received, exception := builtin.Select(scope.Thread, cases, node.hasOtherwise)
+	if exception != nil {
+		return exception
+	}
+	current := node.otherwise
+	if chosen >= 0 {
+		root := node.cases[chosen].Root().(*SelectCaseNode)
+		if root.declaration != nil {
+			scope.Frame.Slots[root.declaration.Slot.Index] = &core.Pointer{Typ: root.declaration.Typ, Variable: received.Variable}
+		}
+		current = root.root
+	}
+	for current != nil {
+		internalReturn := current.Execute(scope)
+		if internalReturn.ReturnType != core.NOTHING {
+			return internalReturn
+		}
+		current = current.Next()
+	}
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+func createSpawnNodeRoot(node *parser.ParseNode, scope *core.Scope) (core.NodeRoot, *core.Type, error) {
+	call, _, err := createNode(node.GetParseNodesWithKey(parser.Children)[0], scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := call.Root().(*FunctionCallNode); !ok {
+		return nil, nil, errors.New("expected a function call after " + node.GetMainToken().ToString())
+	}
+	return &SpawnNode{call: call}, nil, nil
+}
+
+// createChannelOperand compiles the channel a channel operation is applied to and returns the type of its values.
+func createChannelOperand(node *parser.ParseNode, scope *core.Scope) (core.Node, *core.Type, error) {
+	channel, typ, err := createNode(node, scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if typ != core.VariableType && !typ.IsCompatible(builtin.ChannelType) {
+		return nil, nil, errors.New("expected a channel " + node.GetMainToken().ToString())
+	}
+	return channel, builtin.ElementOf(typ), nil
+}
+
+// createIntrinsicNodeRoot compiles a call to channel, send, receive or close.
+func createIntrinsicNodeRoot(node *parser.ParseNode, scope *core.Scope, declaration *core.Pointer) (core.NodeRoot, *core.Type, error) {
+	name := node.GetMainToken().GetValue()
+	parameters := node.GetParseNodesWithKey(parser.Parameters)
+	expected := 1
+	switch declaration {
+	case builtin.ChannelFunction:
+		if len(parameters) == 2 {
+			expected = 2
+		}
+	case builtin.SendFunction:
+		expected = 2
+	}
+	if len(parameters) != expected {
+		return nil, nil, errors.New(fmt.Sprintf("%s takes %d parameters but %d were given %s", name, expected, len(parameters), node.GetMainToken().ToString()))
+	}
+	if declaration == builtin.ChannelFunction {
+		if parameters[0].GetType() != parser.Variable {
+			return nil, nil, errors.New("expected the type of the values of the channel " + parameters[0].GetMainToken().ToString())
+		}
+		element, err := resolveDeclarationType(parameters[0].GetMainToken(), scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		var size core.Node
+		if len(parameters) == 2 {
+			var typ *core.Type
+			size, typ, err = createNode(parameters[1], scope, false, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !typ.IsCompatible(builtin.IntegerType) {
+				return nil, nil, errors.New("expected integer " + parameters[1].GetMainToken().ToString())
+			}
+		}
+		return &ChannelNode{element: element, size: size}, builtin.NewChannelSubType(element), nil
+	}
+	channel, element, err := createChannelOperand(parameters[0], scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch declaration {
+	case builtin.SendFunction:
+		value, _, err := createSentValue(parameters[1], scope, element)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &SendNode{channel: channel, value: value}, nil, nil
+	case builtin.ReceiveFunction:
+		return &ReceiveNode{channel: channel}, element, nil
+	}
+	return &CloseNode{channel: channel}, nil, nil
+}
+
+// createSentValue compiles a value sent on a channel of values of type element.
+func createSentValue(node *parser.ParseNode, scope *core.Scope, element *core.Type) (core.Node, *core.Type, error) {
+	value, typ, err := createNode(node, scope, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if typ == nil {
+		return nil, nil, errors.New("parameter does not return a variable " + node.GetMainToken().ToString())
+	}
+	if !isAssignable(typ, element) {
+		return nil, nil, errors.New("channel of " + element.Name + " can not hold " + typ.Name + " " + node.GetMainToken().ToString())
+	}
+	value, typ = promote(value, typ, element)
+	return value, typ, nil
+}
+
+func createSelectNodeRoot(node *parser.ParseNode, scope *core.Scope, expectedReturnType *core.Type) (core.NodeRoot, *core.Type, error) {
+	var cases []core.Node
+	for _, c := range node.GetParseNodesWithKey(parser.Children) {
+		root, err := createSelectCaseNodeRoot(c, scope, expectedReturnType)
+		if err != nil {
+			return nil, nil, err
+		}
+		cases = append(cases, core.NewNode(root, c.GetMainToken().ToString()))
+	}
+	if len(cases) == 0 {
+		return nil, nil, errors.New("expected a receive or send case " + node.GetMainToken().ToString())
+	}
+	selection := &SelectNode{cases: cases}
+	if otherwise := node.GetParseNodesWithKey(parser.Otherwise); len(otherwise) > 0 {
+		selection.hasOtherwise = true
+		scope.CreateBlock()
+		root, err := parseBlock(otherwise[0], scope, expectedReturnType)
+		scope.ReleaseBlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		selection.otherwise = root
+	}
+	return selection, nil, nil
+}
+
+func createSelectCaseNodeRoot(node *parser.ParseNode, scope *core.Scope, expectedReturnType *core.Type) (*SelectCaseNode, error) {
+	name := node.GetMainToken().GetValue()
+	declaration := scope.MustGet(name)
+	if declaration != builtin.ReceiveFunction && declaration != builtin.SendFunction {
+		return nil, errors.New("expected receive or send " + node.GetMainToken().ToString())
+	}
+	parameters := node.GetParseNodesWithKey(parser.Parameters)
+	c := &SelectCaseNode{send: declaration == builtin.SendFunction}
+	expected := 1
+	if c.send {
+		expected = 2
+	}
+	if len(parameters) != expected {
+		return nil, errors.New(fmt.Sprintf("%s takes %d parameters but %d were given %s", name, expected, len(parameters), node.GetMainToken().ToString()))
+	}
+	as := node.GetTokenWithKey(parser.Identifier)
+	if c.send && as != nil {
+		return nil, errors.New("send does not receive a value " + as.ToString())
+	}
+	channel, element, err := createChannelOperand(parameters[0], scope)
+	if err != nil {
+		return nil, err
+	}
+	c.channel = channel
+	if c.send {
+		c.value, _, err = createSentValue(parameters[1], scope, element)
+		if err != nil {
+			return nil, err
+		}
+	}
+	scope.CreateBlock()
+	defer scope.ReleaseBlock()
+	if as != nil {
+		scope.Declare(as.GetValue(), element)
+		c.declaration = scope.MustGetFromCurrentBlock(as.GetValue())
+	}
+	c.root, err = parseBlock(node.GetParseNodesWithKey(parser.Children)[0], scope, expectedReturnType)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
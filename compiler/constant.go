@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+)
+
+type ConstantNode struct {
+	pointer *core.Pointer
+}
+
+func (node *ConstantNode) Execute(scope *core.Scope) *core.Return {
+	return &core.Return{ReturnType: core.NOTHING, Pointer: node.pointer}
+}
+
+type ConstantDeclarationNode struct {
+	identifier string
+	pointer    *core.Pointer
+}
+
+func (node *ConstantDeclarationNode) Execute(scope *core.Scope) *core.Return {
+	return &core.Return{ReturnType: core.NOTHING, Pointer: node.pointer}
+}
+
+// isConstant reports whether node evaluates to the same value every time without side effects.
+func isConstant(node core.Node) bool {
+	if node == nil {
+		return true
+	}
+	switch root := node.Root().(type) {
+	case *IntegerNode, *BigIntegerNode, *StringNode, *BooleanNode, *ConstantNode:
+		return true
+	case *ConversionNode:
+		return isConstant(root.node)
+	case *SummationNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *SubtractionNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *MultiplicationNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *DivisionNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *BigIntegerArithmeticNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *ConcatenationNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *EqualityNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *InequalityNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *GreaterNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *GreaterOrEqualNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *LessNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *LessOrEqualNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *OrNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *AndNode:
+		return isConstant(root.left) && isConstant(root.right)
+	case *CsvNode:
+		for _, child := range root.children {
+			if !isConstant(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// createBinding compiles the initializer of a const or let statement and returns it together with the name and the
+// type of the binding.
+func createBinding(node *parser.ParseNode, scope *core.Scope) (string, core.Node, *core.Type, error) {
+	target := node.GetParseNodesWithKey(parser.Children)[0]
+	value, valueType, err := createNode(node.GetParseNodesWithKey(parser.Children)[1], scope, false, nil)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if valueType == nil {
+		return "", nil, nil, errors.New("right side does not return a variable " + node.GetMainToken().ToString())
+	}
+	name := target.GetMainToken().GetValue()
+	typ := valueType
+	if target.GetType() == parser.Declaration {
+		name = target.GetTokenWithKey(parser.Identifier).GetValue()
+		typ, err = resolveDeclarationType(target.GetMainToken(), scope)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if !isAssignable(valueType, typ) {
+			return "", nil, nil, errors.New("incompatible types " + node.GetMainToken().ToString())
+		}
+		value, _ = promote(value, valueType, typ)
+	}
+	existing := scope.MustGetFromCurrentBlock(name)
+	if existing != nil && existing.Immutable {
+		return "", nil, nil, errors.New(name + " is already declared " + target.GetMainToken().ToString())
+	}
+	return name, value, typ, nil
+}
+
+func createConstantNodeRoot(node *parser.ParseNode, scope *core.Scope) (core.NodeRoot, *core.Type, error) {
+	name, value, typ, err := createBinding(node, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isConstant(value) {
+		return nil, nil, errors.New("initializer of constant " + name + " is not a constant expression " + node.GetMainToken().ToString())
+	}
+	// Like the constants folded by the optimizer, the initializer does not count against the limits of the program.
+	evaluation := scope.Clone()
+	evaluation.Thread = nil
+	res := value.Execute(evaluation)
+	if res.ReturnType == core.EXCEPTION {
+		return nil, nil, errors.New("constant " + name + " could not be evaluated: " + res.Pointer.Variable.VariableInterface.(*core.StackTrace).ExceptionMessage + " " + node.GetMainToken().ToString())
+	}
+	pointer := &core.Pointer{Typ: typ, Variable: res.Pointer.Variable, Immutable: true, Constant: true}
+	scope.DeclareAndSet(name, pointer)
+	return &ConstantDeclarationNode{identifier: name, pointer: pointer}, typ, nil
+}
+
+func createLetNodeRoot(node *parser.ParseNode, scope *core.Scope) (core.NodeRoot, *core.Type, error) {
+	name, value, typ, err := createBinding(node, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	pointer := &core.Pointer{Typ: typ, Variable: nil, Immutable: true}
+	scope.DeclareAndSet(name, pointer)
+	declaration := core.NewNode(&DeclarationNode{typ: typ, identifier: name, declaration: pointer}, node.GetMainToken().ToString())
+	return &SetNode{leftSide: declaration, rightSide: value}, typ, nil
+}
@@ -0,0 +1,33 @@
+package core
+
+// Slot locates a variable declared by a compiled program. Level is the number of functions the declaration is nested
+// in and Index is its position within the frame of that function.
+type Slot struct {
+	Level int
+	Index int
+}
+
+// Frame holds the variables of a single function invocation, or of the top level of a program. The variables of
+// enclosing functions are reached through Parent.
+type Frame struct {
+	Slots  []*Pointer
+	Parent *Frame
+}
+
+func NewFrame(size int, parent *Frame) *Frame {
+	return &Frame{Slots: make([]*Pointer, size), Parent: parent}
+}
+
+// Get returns the variable at index of the frame depth levels above this one.
+func (frame *Frame) Get(depth int, index int) *Pointer {
+	for ; depth > 0; depth-- {
+		frame = frame.Parent
+	}
+	return frame.Slots[index]
+}
+
+// Reserve adds a slot to the frame and returns its index.
+func (frame *Frame) Reserve() int {
+	frame.Slots = append(frame.Slots, nil)
+	return len(frame.Slots) - 1
+}
@@ -1,5 +1,9 @@
 package core
 
+import (
+	"fmt"
+)
+
 type Parameter struct {
 	Name         string
 	Typ          *Type
@@ -20,6 +24,8 @@ type CustomFunction struct {
 	Typ        *Type
 	ReturnType *Type
 	Scope      *Scope
+	// FrameSize is the number of slots the function needs for its parameters and variables.
+	FrameSize int
 }
 
 func (c *CustomFunction) GetScope() *Scope {
@@ -27,8 +33,6 @@ func (c *CustomFunction) GetScope() *Scope {
 }
 
 func (c *CustomFunction) Execute(scope *Scope) *Return {
-	scope.CreateBlock()
-	defer scope.ReleaseBlock()
 	node := c.EntryNode
 	for node != nil {
 		internalReturn := node.Execute(scope)
@@ -54,3 +58,110 @@ func (c *CustomFunction) GetParameters() []*Parameter {
 func (c *CustomFunction) GetReturnType() *Type {
 	return c.ReturnType
 }
+
+func (c *CustomFunction) GetFrameSize() int {
+	return c.FrameSize
+}
+
+// FrameFunction is implemented by functions that receive their arguments in the first slots of a new frame, see
+// Scope.Enter. Other functions receive them as variables named after their parameters.
+type FrameFunction interface {
+	Function
+	GetFrameSize() int
+}
+
+// FunctionOf returns the function the pointer holds, or an exception when it does not hold one.
+func FunctionOf(pointer *Pointer, name string) (Function, *Return) {
+	if pointer == nil || pointer.Variable == nil {
+		return nil, NewExceptionReturn("uninitialized variable " + name)
+	}
+	function, ok := pointer.Variable.VariableInterface.(Function)
+	if !ok {
+		return nil, NewExceptionReturn(name + " is not a function but " + pointer.Variable.GetType().Name)
+	}
+	return function, nil
+}
+
+// BindArguments stores the arguments of a call to a function named name in slots. Parameters without an argument
+// get their default value.
+func BindArguments(slots []*Pointer, parameters []*Parameter, arguments []*Pointer, name string) *Return {
+	for i, parameter := range parameters {
+		argument := parameter.DefaultValue
+		if i < len(arguments) {
+			argument = arguments[i]
+		}
+		if argument == nil {
+			return NewExceptionReturn("missing value for parameter " + parameter.Name + " of " + name)
+		}
+		slots[i] = &Pointer{Typ: parameter.Typ, Variable: argument.Variable}
+	}
+	return nil
+}
+
+// Call runs the function named name with the given arguments for thread, counting the call as a step.
+func Call(thread *Thread, function Function, arguments []*Pointer, name string) *Return {
+	if thread != nil && thread.Depth >= thread.MaxDepth {
+		return NewExceptionReturn(fmt.Sprintf("stack overflow, call depth limit of %d exceeded", thread.MaxDepth))
+	}
+	if exception := thread.Step(); exception != nil {
+		return exception
+	}
+	if thread == nil {
+		return Invoke(thread, function, arguments, name)
+	}
+	thread.Depth++
+	res := Invoke(thread, function, arguments, name)
+	thread.Depth--
+	return res
+}
+
+// Spawn calls the function named name with the given arguments in a new task of thread, see Thread.Spawn. The
+// arguments are bound to the values they hold when the task is spawned, and the position of the call is added to the
+// stack trace of the exception the call raises.
+func Spawn(thread *Thread, function Function, arguments []*Pointer, name string, position string) {
+	values := make([]*Pointer, len(arguments))
+	for i, argument := range arguments {
+		if argument != nil {
+			values[i] = &Pointer{Typ: argument.Typ, Variable: argument.Variable}
+		}
+	}
+	arguments = values
+	thread.Spawn(func(thread *Thread) *Return {
+		return AddPositionToStackTrace(Call(thread, function, arguments, name), position)
+	})
+}
+
+// Invoke runs the function like Call but does not count the call, which is how calls in tail position are made. The
+// calls the function makes in tail position are made here, in a loop, rather than by the function itself.
+func Invoke(thread *Thread, function Function, arguments []*Pointer, name string) *Return {
+	res := call(thread, function, arguments, name)
+	for res.ReturnType == CALL {
+		res = call(thread, res.Call.Function, res.Call.Arguments, res.Call.Name)
+	}
+	return res
+}
+
+func call(thread *Thread, function Function, arguments []*Pointer, name string) *Return {
+	if frameFunction, ok := function.(FrameFunction); ok {
+		scope := frameFunction.GetScope().Enter(frameFunction.GetFrameSize(), thread)
+		exception := BindArguments(scope.Frame.Slots, frameFunction.GetParameters(), arguments, name)
+		if exception != nil {
+			return exception
+		}
+		return frameFunction.Execute(scope)
+	}
+	scope := function.GetScope().Clone()
+	scope.Thread = thread
+	scope.CreateBlock()
+	for i, parameter := range function.GetParameters() {
+		argument := parameter.DefaultValue
+		if i < len(arguments) {
+			argument = arguments[i]
+		}
+		if argument == nil {
+			return NewExceptionReturn("missing value for parameter " + parameter.Name + " of " + name)
+		}
+		scope.DeclareAndSet(parameter.Name, &Pointer{Typ: parameter.Typ, Variable: argument.Variable})
+	}
+	return function.Execute(scope)
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"sync"
+)
+
+// deadlockMessage is the message of the exception raised by the tasks of a program once every one of them waits for
+// another one.
+const deadlockMessage = "deadlock, every task is waiting for another one"
+
+// monitor guards the channels of a program whose tasks run in parallel. A task holds its lock while it uses a channel
+// and waits with Wait, which releases it, until another task changes a channel. The monitor counts the tasks that have
+// not finished and the ones waiting since the last change, so that it knows when every task waits for another one. The
+// waiting tasks raise an exception then instead of blocking forever, like they do on a Scheduler.
+type monitor struct {
+	mutex sync.Mutex
+	// tasks is the number of tasks that have not finished, including the one that started the program.
+	tasks int
+	// waiting is the number of tasks that waited since the last change.
+	waiting int
+	// changed is closed and replaced when a task changes a channel.
+	changed chan struct{}
+	// deadlocked is set once waiting reached tasks. It stays set, so that the tasks woken by the change Wait makes then
+	// raise the deadlock too instead of counting themselves again.
+	deadlocked bool
+}
+
+// monitor returns the monitor of the program, which is created on first use. Only the thread that started the program
+// can use it first, since Spawn creates it before the first task starts.
+func (thread *Thread) monitor() *monitor {
+	if thread == nil {
+		return nil
+	}
+	program := thread.program()
+	if program.channels == nil {
+		program.channels = &monitor{tasks: 1, changed: make(chan struct{})}
+	}
+	return program.channels
+}
+
+// Lock locks the channels of the program. A scheduled task holds the turn instead, which is enough to use them.
+func (thread *Thread) Lock() {
+	if !thread.Scheduled() {
+		if m := thread.monitor(); m != nil {
+			m.mutex.Lock()
+		}
+	}
+}
+
+// Unlock unlocks the channels of the program locked with Lock.
+func (thread *Thread) Unlock() {
+	if !thread.Scheduled() {
+		if m := thread.monitor(); m != nil {
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// Notify tells the waiting tasks that the task changed a channel, so that they check again whether they can proceed.
+// A scheduled task may lose the turn to another task as well. The channels must be locked.
+func (thread *Thread) Notify() {
+	if thread.Scheduled() {
+		thread.Yield()
+		return
+	}
+	thread.monitor().notify()
+}
+
+// Wait waits until another task changes a channel because this one can not proceed until then. The channels must be
+// locked, they are unlocked while the task waits. The caller checks again whether it can proceed once Wait returns,
+// unless Wait returns an exception because the context of the thread is done or every task of the program is waiting.
+func (thread *Thread) Wait() *Return {
+	if thread.Scheduled() {
+		return thread.waitTurn()
+	}
+	m := thread.monitor()
+	if m == nil {
+		// Without a thread nothing else can run, so nothing would wake the task.
+		return newInterruptionReturn(deadlockMessage)
+	}
+	if exception := thread.Cancelled(); exception != nil {
+		return exception
+	}
+	if m.deadlocked {
+		return newInterruptionReturn(deadlockMessage)
+	}
+	m.waiting++
+	if m.waiting == m.tasks {
+		m.deadlocked = true
+		m.notify()
+		return newInterruptionReturn(deadlockMessage)
+	}
+	changed := m.changed
+	m.mutex.Unlock()
+	select {
+	case <-changed:
+	case <-thread.Done():
+	}
+	m.mutex.Lock()
+	if exception := thread.Cancelled(); exception != nil {
+		return exception
+	}
+	if m.deadlocked {
+		return newInterruptionReturn(deadlockMessage)
+	}
+	return nil
+}
+
+// notify wakes the waiting tasks, which count themselves again if they still have to wait.
+func (m *monitor) notify() {
+	if m == nil {
+		return
+	}
+	m.waiting = 0
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// start counts a task that is about to start.
+func (m *monitor) start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tasks++
+}
+
+// finish counts a task that finished and wakes the tasks that wait for it to.
+func (m *monitor) finish() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tasks--
+	m.notify()
+}
@@ -3,4 +3,10 @@ package core
 type Pointer struct {
 	Typ      *Type
 	Variable *Variable
+	// Immutable pointers can not be assigned to after they are declared.
+	Immutable bool
+	// Constant pointers are immutable and hold a value that is known at compile time.
+	Constant bool
+	// Slot is assigned to declarations by the resolver, declarations without a slot are shared by all programs.
+	Slot *Slot
 }
@@ -8,9 +8,13 @@ const (
 	CONTINUE
 	RETURN
 	EXCEPTION
+	// CALL is returned by functions that end with a call in tail position, see TailCall.
+	CALL
 )
 
 type Return struct {
 	ReturnType ReturnType
 	Pointer    *Pointer
+	// Call is the call to make in place of the function for a Return of type CALL.
+	Call *TailCall
 }
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"math/rand"
+)
+
+// Scheduler runs the tasks of a program one at a time instead of in parallel. A task holds the turn until it steps,
+// uses a channel, spawns or joins, and the scheduler then passes the turn to a task it picks with a random number
+// generator. A program that gets the same input behaves the same way every time it runs with the same seed, so that
+// an ordering bug found with one seed can be replayed.
+//
+// Since only one task runs at a time, the scheduler also knows when every task waits for another one. The waiting
+// tasks raise an exception then instead of blocking forever.
+type Scheduler struct {
+	Seed   int64
+	random *rand.Rand
+	// tasks are the tasks that have not finished yet, including the one holding the turn.
+	tasks []*turn
+	// deadlocked is set once a task waited while no other task was ready to take the turn. From then on a waiting task
+	// passes the turn to any other one, so that every task gets the turn to raise the deadlock.
+	deadlocked bool
+}
+
+// turn is a task of a scheduler, it runs while it holds the turn and waits on wake otherwise.
+type turn struct {
+	wake chan struct{}
+	// waiting is set when the task can not proceed until another task makes progress.
+	waiting bool
+}
+
+func NewScheduler(seed int64) *Scheduler {
+	return &Scheduler{Seed: seed, random: rand.New(rand.NewSource(seed))}
+}
+
+func (s *Scheduler) add() *turn {
+	t := &turn{wake: make(chan struct{}, 1)}
+	s.tasks = append(s.tasks, t)
+	return t
+}
+
+func (s *Scheduler) remove(t *turn) {
+	for i, task := range s.tasks {
+		if task == t {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			return
+		}
+	}
+}
+
+// progress lets the waiting tasks check again whether they can proceed.
+func (s *Scheduler) progress() {
+	for _, t := range s.tasks {
+		t.waiting = false
+	}
+}
+
+// pick returns a random task among the ones accepted by filter, or nil when there is none.
+func (s *Scheduler) pick(filter func(*turn) bool) *turn {
+	var candidates []*turn
+	for _, t := range s.tasks {
+		if filter(t) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[s.random.Intn(len(candidates))]
+}
+
+// pass gives the turn of current to next and waits until current gets it back. Current is nil for a finished task,
+// which does not wait.
+func (s *Scheduler) pass(current, next *turn) {
+	if next == nil || next == current {
+		return
+	}
+	next.wake <- struct{}{}
+	if current != nil {
+		<-current.wake
+	}
+}
+
+// Scheduled reports whether the tasks of the thread run on a Scheduler.
+func (thread *Thread) Scheduled() bool {
+	return thread != nil && thread.Scheduler != nil
+}
+
+// schedule returns the turn of the thread. The thread that started the program gets its turn on its first use.
+func (thread *Thread) schedule() *turn {
+	if thread.turn == nil {
+		thread.turn = thread.Scheduler.add()
+	}
+	return thread.turn
+}
+
+// Yield tells the scheduler that the task made progress and lets it pass the turn to another task. It does nothing
+// when the thread is not scheduled.
+func (thread *Thread) Yield() {
+	if !thread.Scheduled() || len(thread.Scheduler.tasks) < 2 {
+		return
+	}
+	s := thread.Scheduler
+	s.progress()
+	s.pass(thread.schedule(), s.pick(func(*turn) bool {
+		return true
+	}))
+}
+
+// waitTurn passes the turn to another task because this one can not proceed until another task makes progress, see
+// Wait. Once the context of the thread is done or every task of the program is waiting, the other tasks get the turn
+// to give up as well.
+func (thread *Thread) waitTurn() *Return {
+	s := thread.Scheduler
+	current := thread.schedule()
+	current.waiting = true
+	ready := func(t *turn) bool {
+		return !t.waiting
+	}
+	if !s.deadlocked && thread.Cancelled() == nil && s.pick(ready) == nil {
+		s.deadlocked = true
+	}
+	if s.deadlocked || thread.Cancelled() != nil {
+		ready = func(t *turn) bool {
+			return t != current
+		}
+	}
+	s.pass(current, s.pick(ready))
+	if exception := thread.Cancelled(); exception != nil {
+		return exception
+	}
+	if s.deadlocked {
+		return newInterruptionReturn(deadlockMessage)
+	}
+	return nil
+}
+
+// Pick returns a random number in [0, n). It is drawn from the scheduler of a scheduled thread, so that the choices the
+// program makes between its tasks are replayed as well.
+func (thread *Thread) Pick(n int) int {
+	if !thread.Scheduled() {
+		return rand.Intn(n)
+	}
+	return thread.Scheduler.random.Intn(n)
+}
+
+// finish removes the finished task of the thread from the scheduler and passes the turn to another task.
+func (thread *Thread) finish() {
+	s := thread.Scheduler
+	s.remove(thread.turn)
+	s.progress()
+	s.pass(nil, s.pick(func(*turn) bool {
+		return true
+	}))
+}
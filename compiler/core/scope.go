@@ -23,17 +23,29 @@ func NewScopeBlock(references map[string]*Pointer) *ScopeBlock {
 type Scope struct {
 	Blocks []*ScopeBlock
 	Name   string
+	// Frame holds the variables of the function that is being executed in the scope.
+	Frame *Frame
+	// Thread is the program the function being executed in the scope belongs to.
+	Thread *Thread
 }
 
+// Clone returns a copy of the scope with a list of blocks of its own. Scopes shared by all programs, such as the scopes
+// of types and natives, are only ever extended through a clone, so that programs can run in parallel.
 func (scope *Scope) Clone() *Scope {
 	if scope == nil {
 		panic("cloning a nil scope")
 	}
-	return &Scope{Blocks: append([]*ScopeBlock(nil), scope.Blocks...), Name: scope.Name + "-Copy"}
+	return &Scope{Blocks: append([]*ScopeBlock(nil), scope.Blocks...), Name: scope.Name + "-Copy", Frame: scope.Frame, Thread: scope.Thread}
 }
 
 func (scope *Scope) CloneWithName(name string) *Scope {
-	return &Scope{Blocks: append([]*ScopeBlock(nil), scope.Blocks...), Name: scope.Name + "-" + name + "Copy"}
+	return &Scope{Blocks: append([]*ScopeBlock(nil), scope.Blocks...), Name: scope.Name + "-" + name + "Copy", Frame: scope.Frame, Thread: scope.Thread}
+}
+
+// Enter returns a scope for a call made by thread, with a new frame of the given size whose parent is the frame of
+// this scope.
+func (scope *Scope) Enter(size int, thread *Thread) *Scope {
+	return &Scope{Name: scope.Name, Frame: NewFrame(size, scope.Frame), Thread: thread}
 }
 
 func NewScope() *Scope {
@@ -41,10 +53,10 @@ func NewScope() *Scope {
 }
 
 func NewScopeWithName(name string) *Scope {
-	return &Scope{Name: name}
+	return &Scope{Name: name, Frame: NewFrame(0, nil)}
 }
 
-func (scope *Scope) getCurrentBlock() *ScopeBlock {
+func (scope *Scope) CurrentBlock() *ScopeBlock {
 	return scope.Blocks[len(scope.Blocks)-1]
 }
 
@@ -103,16 +115,21 @@ func (scope *Scope) MustGet(name string) *Pointer {
 	return nil
 }
 
+// MustGetFromCurrentBlock returns the pointer declared with the given name in the innermost block or nil.
+func (scope *Scope) MustGetFromCurrentBlock(name string) *Pointer {
+	return scope.CurrentBlock().Get(name)
+}
+
 func (scope *Scope) DeclareAndSet(name string, value *Pointer) {
-	scope.getCurrentBlock().References[name] = value
+	scope.CurrentBlock().References[name] = value
 }
 
 func (scope *Scope) Set(name string, value *Pointer) {
-	scope.getCurrentBlock().References[name].Variable = value.Variable
+	scope.CurrentBlock().References[name].Variable = value.Variable
 }
 
 func (scope *Scope) Declare(name string, typ *Type) {
-	scope.getCurrentBlock().References[name] = &Pointer{Typ: typ, Variable: nil}
+	scope.CurrentBlock().References[name] = &Pointer{Typ: typ, Variable: nil}
 }
 
 func (scope *Scope) SetName(name string) {
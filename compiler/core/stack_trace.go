@@ -1,13 +1,27 @@
 package core
 
+import (
+	"fmt"
+	"strings"
+)
+
 var StackTraceType = &Type{Name: "StackTrace", Parent: VariableType, Methods: map[string]Function{}, Converters: map[*Type]Function{}, Scope: NewScope()}
 
 type StackTrace struct {
 	ExceptionMessage string
 	Positions        []string
+	// Exit is set for the exception raised to end the program with ExitCode as the exit status, see NewExitReturn.
+	Exit     bool
+	ExitCode int
+	// Interrupted is set for the exceptions raised by a deadlock or a cancellation, see Interrupted.
+	Interrupted bool
 }
 
 func (s *StackTrace) AddPosition(position string) {
+	// Nodes synthesized by the compiler share the position of the node they wrap.
+	if len(s.Positions) > 0 && s.Positions[len(s.Positions)-1] == position {
+		return
+	}
 	s.Positions = append(s.Positions, position)
 }
 
@@ -15,13 +29,50 @@ func (*StackTrace) GetType() *Type {
 	return StackTraceType
 }
 
+// maxRepeatedPositions is the length of the longest sequence of positions GetStringValue recognizes as repeated.
+const maxRepeatedPositions = 16
+
+// GetStringValue returns the message followed by the positions, one per line. A sequence of positions that repeats
+// more than twice, as deep recursion produces, is printed once followed by the number of repetitions.
 func (s *StackTrace) GetStringValue() string {
-	msg := s.ExceptionMessage
-	for _, position := range s.Positions {
-		msg += "\n"
-		msg += position
+	builder := &strings.Builder{}
+	builder.WriteString(s.ExceptionMessage)
+	for i := 0; i < len(s.Positions); {
+		length, count := repetition(s.Positions[i:])
+		for _, position := range s.Positions[i : i+length] {
+			builder.WriteString("\n")
+			builder.WriteString(position)
+		}
+		if count > 1 {
+			builder.WriteString(fmt.Sprintf("\n[previous %d positions repeated %d more times]", length, count-1))
+		}
+		i += length * count
+	}
+	return builder.String()
+}
+
+// repetition returns the length of the shortest sequence at the start of positions that repeats more than twice in a
+// row and the number of times it appears, or 1 and 1 when there is no such sequence.
+func repetition(positions []string) (int, int) {
+	for length := 1; length <= maxRepeatedPositions && 3*length <= len(positions); length++ {
+		count := 1
+		for (count+1)*length <= len(positions) && equalPositions(positions[:length], positions[count*length:(count+1)*length]) {
+			count++
+		}
+		if count > 2 {
+			return length, count
+		}
 	}
-	return msg
+	return 1, 1
+}
+
+func equalPositions(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func NewStackTracePointer(exceptionMessage string) *Pointer {
@@ -38,6 +89,44 @@ func NewExceptionReturn(message string) *Return {
 	}
 }
 
+// NewExitReturn returns the exception that ends the program with the given exit status. It unwinds the program like
+// any other exception. An exit in a spawned task cancels the other tasks as well, see Thread.Spawn.
+func NewExitReturn(code int) *Return {
+	r := NewExceptionReturn(fmt.Sprintf("exit status %d", code))
+	trace := r.Pointer.Variable.VariableInterface.(*StackTrace)
+	trace.Exit = true
+	trace.ExitCode = code
+	return r
+}
+
+// newInterruptionReturn returns the exception raised when a task is stopped by a deadlock or a cancellation instead of
+// by its own code.
+func newInterruptionReturn(message string) *Return {
+	r := NewExceptionReturn(message)
+	r.Pointer.Variable.VariableInterface.(*StackTrace).Interrupted = true
+	return r
+}
+
+// Interrupted reports whether r is an exception raised by a deadlock or a cancellation. Such an exception is often the
+// consequence of an exception raised by another task, e.g. a task waiting for a value that a failed task never sent,
+// so the exception of the other task takes priority when both are reported.
+func Interrupted(r *Return) bool {
+	if r == nil || r.ReturnType != EXCEPTION {
+		return false
+	}
+	trace, ok := r.Pointer.Variable.VariableInterface.(*StackTrace)
+	return ok && trace.Interrupted
+}
+
+// exits reports whether r is an exception returned by NewExitReturn.
+func exits(r *Return) bool {
+	if r == nil || r.ReturnType != EXCEPTION {
+		return false
+	}
+	trace, ok := r.Pointer.Variable.VariableInterface.(*StackTrace)
+	return ok && trace.Exit
+}
+
 func AddPositionToStackTrace(r *Return, position string) *Return {
 	if r.ReturnType != EXCEPTION {
 		return r
@@ -0,0 +1,365 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxCallDepth is the number of nested calls a program can make before a stack overflow exception is raised.
+const DefaultMaxCallDepth = 10000
+
+// stepsPerContextCheck is the number of steps after which Step checks whether the context of the thread is done.
+const stepsPerContextCheck = 1024
+
+// Thread is the state of a running program that is shared by all the functions it calls. Every task the program
+// spawns runs on a thread of its own, see Spawn.
+type Thread struct {
+	// Context stops the program when it is done, it is checked periodically by Step.
+	Context context.Context
+	// Depth is the number of calls that have not returned yet, calls in tail position are not counted.
+	Depth    int
+	MaxDepth int
+	// Steps is the number of function calls and loop iterations the program made. MaxSteps limits it when it is
+	// positive. The steps of spawned tasks are counted by the thread that started the program.
+	Steps    int64
+	MaxSteps int64
+	// Allocated is the number of bytes of strings, big integers, sets and channel buffers the program created.
+	// MaxAllocated limits it when it is positive. Like steps, the bytes of spawned tasks are counted by the thread that
+	// started the program.
+	Allocated    int64
+	MaxAllocated int64
+	// Stdin, Stdout and Stderr are the streams of the program, the streams of the process are used when they are nil.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Args are the arguments the program was started with.
+	Args []string
+	// Scheduler runs the tasks of the program one at a time when it is set, they run in parallel otherwise.
+	Scheduler *Scheduler
+	// turn is the task of the thread on the scheduler.
+	turn *turn
+	// root is the thread that started the program, it is nil for that thread itself.
+	root *Thread
+	// streams serializes the use of the streams once the program has spawned a task.
+	streams *sync.Mutex
+	// channels guards the channels of the program when its tasks run in parallel, see monitor.
+	channels *monitor
+	// tasks are the tasks spawned by the thread that have not been joined yet.
+	tasks []*task
+	// cancel cancels the context of the program once it has spawned a task, so that an exit in a task stops the others.
+	cancel context.CancelFunc
+	// exited holds the exit exception of the first task that called exit, see Exited.
+	exited atomic.Value
+}
+
+// task is a function running in a goroutine of its own, its result is set before done is closed.
+type task struct {
+	done   chan struct{}
+	result *Return
+}
+
+func (t *task) finished() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (thread *Thread) program() *Thread {
+	if thread.root != nil {
+		return thread.root
+	}
+	return thread
+}
+
+// Step counts a function call or a loop iteration and returns an exception when the program has run out of steps or
+// its context is done. A scheduled task may lose the turn to another task at every step.
+func (thread *Thread) Step() *Return {
+	if thread == nil {
+		return nil
+	}
+	steps := atomic.AddInt64(&thread.program().Steps, 1)
+	if thread.MaxSteps > 0 && steps > thread.MaxSteps {
+		return NewExceptionReturn(fmt.Sprintf("step limit of %d exceeded", thread.MaxSteps))
+	}
+	if thread.Context != nil && steps%stepsPerContextCheck == 0 {
+		if exception := thread.Cancelled(); exception != nil {
+			return exception
+		}
+	}
+	thread.Yield()
+	return nil
+}
+
+// Cancelled returns an exception when the context of the thread is done.
+func (thread *Thread) Cancelled() *Return {
+	if thread == nil || thread.Context == nil {
+		return nil
+	}
+	if err := thread.Context.Err(); err != nil {
+		return newInterruptionReturn("execution cancelled: " + err.Error())
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the context of the thread is done, or nil when it has no context.
+func (thread *Thread) Done() <-chan struct{} {
+	if thread == nil || thread.Context == nil {
+		return nil
+	}
+	return thread.Context.Done()
+}
+
+// Allocate counts size bytes allocated by the program and returns an exception when it has run out of memory.
+func (thread *Thread) Allocate(size int64) *Return {
+	if thread == nil || size == 0 {
+		return nil
+	}
+	allocated := atomic.AddInt64(&thread.program().Allocated, size)
+	if thread.MaxAllocated > 0 && allocated > thread.MaxAllocated {
+		return NewExceptionReturn(fmt.Sprintf("memory limit of %d bytes exceeded", thread.MaxAllocated))
+	}
+	return nil
+}
+
+// Spawn runs f in a new goroutine on a new thread that shares the context, the limits, the streams, the arguments and
+// the scheduler of this one. The exception f returns, or the exception of a task it spawned and did not join, is
+// returned by Join. When the exception is an exit, the context of the program is cancelled right away, so that the
+// other tasks stop instead of running until they are joined.
+func (thread *Thread) Spawn(f func(*Thread) *Return) {
+	program := thread.program()
+	if program.streams == nil {
+		// Only the thread that started the program can spawn the first task, so nothing else uses the streams or the
+		// context yet.
+		program.streams = &sync.Mutex{}
+		parent := program.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		program.Context, program.cancel = context.WithCancel(parent)
+	}
+	child := &Thread{
+		Context:      thread.Context,
+		MaxDepth:     thread.MaxDepth,
+		MaxSteps:     thread.MaxSteps,
+		MaxAllocated: thread.MaxAllocated,
+		Stdin:        thread.Stdin,
+		Stdout:       thread.Stdout,
+		Stderr:       thread.Stderr,
+		Args:         thread.Args,
+		root:         program,
+		streams:      program.streams,
+		Scheduler:    thread.Scheduler,
+	}
+	var channels *monitor
+	if thread.Scheduled() {
+		thread.schedule()
+		child.turn = thread.Scheduler.add()
+	} else {
+		channels = thread.monitor()
+		channels.start()
+	}
+	t := &task{done: make(chan struct{})}
+	thread.tasks = append(thread.tasks, t)
+	go func() {
+		if child.Scheduled() {
+			<-child.turn.wake
+			defer child.finish()
+		} else {
+			defer channels.finish()
+		}
+		defer close(t.done)
+		defer func() {
+			if r := recover(); r != nil {
+				t.result = NewExceptionReturn(fmt.Sprint("internal error: ", r))
+			}
+		}()
+		t.result = f(child)
+		if exception := child.Join(); exception != nil && (t.result.ReturnType != EXCEPTION || Interrupted(t.result)) {
+			t.result = exception
+		}
+		if exits(t.result) {
+			child.exit(t.result)
+		}
+	}()
+	thread.Yield()
+}
+
+// Join waits for the tasks spawned by the thread since the last join and returns the exception of the first of them
+// that raised one, or nil. An exception raised by the code of a task is returned rather than a deadlock or a
+// cancellation it may have caused in another one, see Interrupted.
+func (thread *Thread) Join() *Return {
+	if thread == nil {
+		return nil
+	}
+	thread.Yield()
+	var exception *Return
+	for _, t := range thread.tasks {
+		// Joining counts as waiting, so that a task joining tasks that wait for it is a deadlock. The task gives up as
+		// well then, since it shares the monitor and the context, and its exception tells where it was waiting. The
+		// exception of Wait is only returned when the task finished without one. A scheduled task keeps waiting for the
+		// turn to pass to it until then.
+		var waited *Return
+		thread.Lock()
+		for !t.finished() {
+			if waited = thread.Wait(); waited != nil && !thread.Scheduled() {
+				break
+			}
+		}
+		thread.Unlock()
+		<-t.done
+		if t.result.ReturnType == EXCEPTION && (exception == nil || Interrupted(exception) && !Interrupted(t.result)) {
+			exception = t.result
+		} else if exception == nil {
+			exception = waited
+		}
+	}
+	thread.tasks = nil
+	if exited := thread.Exited(); exited != nil {
+		return exited
+	}
+	return exception
+}
+
+// exit records the exit exception of a task and cancels the program. Only the first exit is recorded.
+func (thread *Thread) exit(exception *Return) {
+	program := thread.program()
+	if program.exited.CompareAndSwap(nil, exception) {
+		program.cancel()
+	}
+}
+
+// Exited returns the exception of the first task of the program that called exit, or nil. The other tasks are
+// cancelled then, so the exit takes priority over the exceptions they raise.
+func (thread *Thread) Exited() *Return {
+	if thread == nil {
+		return nil
+	}
+	exited, _ := thread.program().exited.Load().(*Return)
+	return exited
+}
+
+// stdin buffers the standard input of the process once, so that programs reading from it do not lose what a previous
+// read buffered. It is shared by all programs, which may run in parallel.
+var stdin io.Reader = &lockedReader{mutex: &sync.Mutex{}, reader: bufio.NewReader(os.Stdin)}
+
+// Input returns the stream the program reads from.
+func (thread *Thread) Input() io.Reader {
+	if thread == nil || thread.Stdin == nil {
+		return stdin
+	}
+	if thread.streams != nil {
+		return &lockedReader{mutex: thread.streams, reader: thread.Stdin}
+	}
+	return thread.Stdin
+}
+
+// Read calls read, which reads from the input of the program, and waits for it to return or for the context of the
+// thread to be done, which returns the exception of Cancelled. A read that is given up keeps waiting for the input in the
+// background and what it reads is lost.
+func (thread *Thread) Read(read func()) *Return {
+	done := thread.Done()
+	if done == nil {
+		read()
+		return nil
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		read()
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-done:
+		return thread.Cancelled()
+	}
+}
+
+// Output returns the stream the program writes to.
+func (thread *Thread) Output() io.Writer {
+	if thread == nil || thread.Stdout == nil {
+		return os.Stdout
+	}
+	if thread.streams != nil {
+		return &lockedWriter{mutex: thread.streams, writer: thread.Stdout}
+	}
+	return thread.Stdout
+}
+
+// ErrorOutput returns the stream the program writes its errors to.
+func (thread *Thread) ErrorOutput() io.Writer {
+	if thread == nil || thread.Stderr == nil {
+		return os.Stderr
+	}
+	if thread.streams != nil {
+		return &lockedWriter{mutex: thread.streams, writer: thread.Stderr}
+	}
+	return thread.Stderr
+}
+
+// lockedReader serializes the reads of a reader. It reads a rune at a time like the reader does, so that scanning it
+// does not lose input, when the reader is an io.RuneScanner.
+type lockedReader struct {
+	mutex  *sync.Mutex
+	reader io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.reader.Read(p)
+}
+
+func (l *lockedReader) ReadRune() (rune, int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if scanner, ok := l.reader.(io.RuneScanner); ok {
+		return scanner.ReadRune()
+	}
+	var b [1]byte
+	n, err := l.reader.Read(b[:])
+	if n == 0 {
+		if err == nil {
+			err = io.ErrNoProgress
+		}
+		return 0, 0, err
+	}
+	return rune(b[0]), 1, nil
+}
+
+func (l *lockedReader) UnreadRune() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if scanner, ok := l.reader.(io.RuneScanner); ok {
+		return scanner.UnreadRune()
+	}
+	return bufio.ErrInvalidUnreadRune
+}
+
+// lockedWriter serializes the writes to a writer.
+type lockedWriter struct {
+	mutex  *sync.Mutex
+	writer io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.writer.Write(p)
+}
+
+// TailCall is a call in tail position. The function making it returns it in a Return of type CALL instead of calling,
+// and Call makes the call in its place, so that the calling function does not stay on the stack.
+type TailCall struct {
+	Function  Function
+	Arguments []*Pointer
+	Name      string
+}
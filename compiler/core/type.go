@@ -15,6 +15,9 @@ func (typ *Type) Is(other *Type) bool {
 }
 
 func (typ *Type) IsConvertable(other *Type) bool {
+	if typ == nil || other == nil {
+		return false
+	}
 	if typ == other {
 		return true
 	}
@@ -24,8 +27,13 @@ func (typ *Type) IsConvertable(other *Type) bool {
 	return false
 }
 
+// IsCompatible reports whether a value of typ can be used where a value of other is expected. That is the case when
+// other is typ itself, one of its ancestors or var, or when both are generic types with compatible generics.
 func (typ *Type) IsCompatible(other *Type) bool {
-	if typ == other {
+	if typ == nil || other == nil {
+		return false
+	}
+	if typ == other || other == VariableType {
 		return true
 	}
 	if typ.Generic && other.Generic && len(typ.Generics) == len(other.Generics) && typ.Parent.IsCompatible(other.Parent) {
@@ -40,12 +48,10 @@ func (typ *Type) IsCompatible(other *Type) bool {
 		}
 		return true
 	}
-	t := typ.Parent
-	for t != nil {
-		if t == other.Parent {
+	for t := typ.Parent; t != nil; t = t.Parent {
+		if t == other {
 			return true
 		}
-		t = t.Parent
 	}
 	return false
 }
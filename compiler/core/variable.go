@@ -8,6 +8,14 @@ type VariableInterface interface {
 
 const Self = "Self"
 
+// Types can define how their values are compared by providing methods with these names. The equals method receives
+// the other value and returns a boolean, the compare method receives the other value and returns a negative, zero or
+// positive integer.
+const (
+	EqualsMethod  = "equals"
+	CompareMethod = "compare"
+)
+
 func NewVariable(variableInterface VariableInterface) *Variable {
 	return &Variable{VariableInterface: variableInterface}
 }
@@ -24,6 +32,9 @@ func (variable *Variable) ToPointer() *Pointer {
 }
 
 func (variable *Variable) ConvertTo(typ *Type) *Return {
+	if variable == nil {
+		return NewExceptionReturn("conversion of an uninitialized value to " + typ.Name + " is not possible")
+	}
 	if variable.GetType().Is(typ) {
 		return &Return{
 			ReturnType: NOTHING,
@@ -42,10 +53,21 @@ func (variable *Variable) ConvertTo(typ *Type) *Return {
 	return NewExceptionReturn("conversion from " + variable.GetType().Name + " to " + typ.Name + " is not possible")
 }
 
-func (variable *Variable) CallMethod(method string) (res *Return) {
+func (variable *Variable) CallMethod(method string, arguments ...*Pointer) (res *Return) {
+	function := variable.GetType().Methods[method]
+	if function == nil {
+		return NewExceptionReturn(variable.GetType().Name + " has no method " + method)
+	}
 	variable.GetType().Scope.CloneWithNewBlock(func(scope *Scope) {
 		scope.DeclareAndSet(Self, variable.ToPointer())
-		res = variable.GetType().Methods[method].Execute(scope)
+		for i, parameter := range function.GetParameters() {
+			if i < len(arguments) {
+				scope.DeclareAndSet(parameter.Name, arguments[i])
+			} else {
+				scope.DeclareAndSet(parameter.Name, parameter.DefaultValue)
+			}
+		}
+		res = function.Execute(scope)
 	})
 	return
 }
@@ -0,0 +1,197 @@
+package compiler
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// optimizer simplifies a compiled tree before it is executed. Constant expressions are evaluated once at compile time,
+// operations with an identity operand are reduced to a conversion of the other operand, branches that can never run
+// are removed and calls in tail position are marked. Replacement nodes take the position of the node they replace, so
+// that exceptions raised below them carry the same positions. Expressions that raise an exception are left alone to
+// raise it at runtime.
+type optimizer struct {
+	scope *core.Scope
+	// function is set while the body of a function is optimized.
+	function bool
+}
+
+func optimize(root core.Node, scope *core.Scope) core.Node {
+	// Constants are evaluated outside of the thread of the program, so they do not count against its limits.
+	scope = scope.Clone()
+	scope.Thread = nil
+	o := &optimizer{scope: scope}
+	return o.block(root)
+}
+
+// block optimizes the statements of a block and returns its new first statement.
+func (o *optimizer) block(node core.Node) core.Node {
+	var first, last core.Node
+	for node != nil {
+		next := node.Next()
+		for _, statement := range o.statement(node) {
+			if first == nil {
+				first = statement
+			} else {
+				last.SetNext(statement)
+			}
+			last = statement
+		}
+		node = next
+	}
+	if last != nil {
+		last.SetNext(nil)
+	}
+	return first
+}
+
+// statement optimizes a statement and returns the statements that replace it.
+func (o *optimizer) statement(node core.Node) []core.Node {
+	if root, ok := node.Root().(*FunctionNode); ok {
+		function := o.function
+		o.function = true
+		root.entryNode = o.block(root.entryNode)
+		o.function = function
+		return []core.Node{node}
+	}
+	for _, child := range children(node) {
+		*child = o.block(*child)
+	}
+	switch root := node.Root().(type) {
+	case *ReturnNode:
+		// The call a function returns is made by the caller of the function, see core.TailCall.
+		if call, ok := root.node.Root().(*FunctionCallNode); ok && o.function {
+			call.tail = true
+		}
+	case *ConditionNode:
+		if condition, ok := o.condition(root.condition); ok {
+			if condition {
+				return statements(root.root)
+			}
+			return statements(root.otherwise)
+		}
+	case *ConditionLoopNode:
+		if condition, ok := o.condition(root.condition); ok && !condition {
+			return nil
+		}
+	}
+	return []core.Node{o.expression(node)}
+}
+
+func statements(node core.Node) []core.Node {
+	var res []core.Node
+	for ; node != nil; node = node.Next() {
+		res = append(res, node)
+	}
+	return res
+}
+
+// expression simplifies an expression whose operands are already optimized.
+func (o *optimizer) expression(node core.Node) core.Node {
+	if _, ok := node.Root().(*ConstantNode); ok {
+		return node
+	}
+	if isConstant(node) {
+		if pointer := o.evaluate(node); pointer != nil {
+			return core.NewNode(&ConstantNode{pointer: pointer}, node.Position())
+		}
+		return node
+	}
+	switch root := node.Root().(type) {
+	case *SummationNode:
+		if o.integer(root.right, 0) {
+			return conversion(root.left, builtin.IntegerType, node)
+		}
+		if o.integer(root.left, 0) {
+			return conversion(root.right, builtin.IntegerType, node)
+		}
+	case *SubtractionNode:
+		if root.left != nil && o.integer(root.right, 0) {
+			return conversion(root.left, builtin.IntegerType, node)
+		}
+	case *MultiplicationNode:
+		if o.integer(root.right, 1) {
+			return conversion(root.left, builtin.IntegerType, node)
+		}
+		if o.integer(root.left, 1) {
+			return conversion(root.right, builtin.IntegerType, node)
+		}
+	case *DivisionNode:
+		if o.integer(root.right, 1) {
+			return conversion(root.left, builtin.IntegerType, node)
+		}
+	case *ConcatenationNode:
+		if o.emptyString(root.right) {
+			return conversion(root.left, builtin.StringType, node)
+		}
+		if o.emptyString(root.left) {
+			return conversion(root.right, builtin.StringType, node)
+		}
+	case *OrNode:
+		if left, ok := o.boolean(root.left); ok {
+			if left {
+				return core.NewNode(&ConstantNode{pointer: builtin.NewBooleanPointer(true)}, node.Position())
+			}
+			return conversion(root.right, builtin.BooleanType, node)
+		}
+	case *AndNode:
+		if left, ok := o.boolean(root.left); ok {
+			if !left {
+				return core.NewNode(&ConstantNode{pointer: builtin.NewBooleanPointer(false)}, node.Position())
+			}
+			return conversion(root.right, builtin.BooleanType, node)
+		}
+	}
+	return node
+}
+
+// conversion replaces node with a conversion of operand, which is what remains of an operation with an identity operand.
+// The conversion fails exactly when the operation would have failed on the operand.
+func conversion(operand core.Node, typ *core.Type, node core.Node) core.Node {
+	return core.NewNode(&ConversionNode{node: operand, typ: typ}, node.Position())
+}
+
+// evaluate returns the value of a constant expression or nil when it raises an exception.
+func (o *optimizer) evaluate(node core.Node) *core.Pointer {
+	res := node.Execute(o.scope)
+	if res.ReturnType != core.NOTHING {
+		return nil
+	}
+	return res.Pointer
+}
+
+// constant returns the value of a folded expression or nil when its value is not known at compile time.
+func (o *optimizer) constant(node core.Node) *core.Pointer {
+	if node == nil {
+		return nil
+	}
+	if root, ok := node.Root().(*ConstantNode); ok {
+		return root.pointer
+	}
+	return nil
+}
+
+// condition returns the value of a folded condition, which unlike the operands of or and and is not converted.
+func (o *optimizer) condition(node core.Node) (bool, bool) {
+	value, exception := builtin.BooleanOf(o.constant(node))
+	return value, exception == nil
+}
+
+func (o *optimizer) boolean(node core.Node) (bool, bool) {
+	pointer := o.constant(node)
+	if pointer == nil {
+		return false, false
+	}
+	value, exception := builtin.ToBoolean(pointer)
+	return value, exception == nil
+}
+
+func (o *optimizer) integer(node core.Node, value int64) bool {
+	i, exception := builtin.IntegerOf(o.constant(node))
+	return exception == nil && i == value
+}
+
+func (o *optimizer) emptyString(node core.Node) bool {
+	s, exception := builtin.StringOf(o.constant(node))
+	return exception == nil && s == ""
+}
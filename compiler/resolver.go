@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// resolver assigns a frame slot to every declaration of a compiled program and locates the declaration each variable
+// reference reads from, so that variables are not looked up by name at runtime. Each function call gets a frame of
+// its own, while the declarations at the top level of a program are stored in the frame of the scope it is compiled
+// against. That frame grows with every program compiled against the scope.
+type resolver struct {
+	global *core.Frame
+	level  int
+	// size is the number of slots used by the function that is being resolved.
+	size int
+}
+
+func resolve(root core.Node, scope *core.Scope) {
+	r := &resolver{global: scope.Frame}
+	r.block(root)
+}
+
+func (r *resolver) declare(declaration *core.Pointer) {
+	if declaration == nil {
+		return
+	}
+	if r.level == 0 {
+		declaration.Slot = &core.Slot{Level: 0, Index: r.global.Reserve()}
+		return
+	}
+	declaration.Slot = &core.Slot{Level: r.level, Index: r.size}
+	r.size++
+}
+
+// locate returns how many frames above the current one the declaration is stored and its index in that frame.
+func (r *resolver) locate(declaration *core.Pointer) (int, int) {
+	if declaration.Slot == nil {
+		return 0, 0
+	}
+	return r.level - declaration.Slot.Level, declaration.Slot.Index
+}
+
+func (r *resolver) block(node core.Node) {
+	for ; node != nil; node = node.Next() {
+		r.walk(node)
+	}
+}
+
+func (r *resolver) walk(node core.Node) {
+	switch root := node.Root().(type) {
+	case *VariableNode:
+		root.depth, root.slot = r.locate(root.declaration)
+		return
+	case *DeclarationNode:
+		r.declare(root.declaration)
+		return
+	case *FunctionCallNode:
+		root.depth, root.slot = r.locate(root.declaration)
+	case *ToLoopNode:
+		r.block(root.from)
+		r.block(root.to)
+		r.declare(root.declaration)
+		r.block(root.root)
+		return
+	case *SelectCaseNode:
+		r.block(root.channel)
+		r.block(root.value)
+		r.declare(root.declaration)
+		r.block(root.root)
+		return
+	case *FunctionNode:
+		r.declare(root.declaration)
+		level, size := r.level, r.size
+		r.level, r.size = r.level+1, 0
+		for _, declaration := range root.parameterDeclarations {
+			r.declare(declaration)
+		}
+		r.block(root.entryNode)
+		root.frameSize = r.size
+		r.level, r.size = level, size
+		return
+	}
+	for _, child := range children(node) {
+		r.block(*child)
+	}
+}
@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// children returns the fields holding the nodes directly below node in the order they are executed, so that passes
+// over the tree can replace them. Blocks are represented by their first statement, the rest of the block is reached
+// with Next.
+func children(node core.Node) []*core.Node {
+	switch root := node.Root().(type) {
+	case *FunctionNode:
+		return []*core.Node{&root.entryNode}
+	case *ConversionNode:
+		return []*core.Node{&root.node}
+	case *SetNode:
+		return []*core.Node{&root.rightSide, &root.leftSide}
+	case *ConditionNode:
+		return []*core.Node{&root.condition, &root.root, &root.otherwise}
+	case *ToLoopNode:
+		return []*core.Node{&root.from, &root.to, &root.root}
+	case *ConditionLoopNode:
+		return []*core.Node{&root.condition, &root.root}
+	case *CsvNode:
+		return fields(root.children)
+	case *FunctionCallNode:
+		return fields(root.parameters)
+	case *ReturnNode:
+		return []*core.Node{&root.node}
+	case *ResultNode:
+		return []*core.Node{&root.node}
+	case *SpawnNode:
+		return []*core.Node{&root.call}
+	case *ChannelNode:
+		return []*core.Node{&root.size}
+	case *SendNode:
+		return []*core.Node{&root.channel, &root.value}
+	case *ReceiveNode:
+		return []*core.Node{&root.channel}
+	case *CloseNode:
+		return []*core.Node{&root.channel}
+	case *SelectNode:
+		return append(fields(root.cases), &root.otherwise)
+	case *SelectCaseNode:
+		return []*core.Node{&root.channel, &root.value, &root.root}
+	case *OrNode:
+		return []*core.Node{&root.left, &root.right}
+	case *AndNode:
+		return []*core.Node{&root.left, &root.right}
+	case *SummationNode:
+		return []*core.Node{&root.left, &root.right}
+	case *SubtractionNode:
+		return []*core.Node{&root.left, &root.right}
+	case *MultiplicationNode:
+		return []*core.Node{&root.left, &root.right}
+	case *DivisionNode:
+		return []*core.Node{&root.left, &root.right}
+	case *BigIntegerArithmeticNode:
+		return []*core.Node{&root.left, &root.right}
+	case *ConcatenationNode:
+		return []*core.Node{&root.left, &root.right}
+	case *EqualityNode:
+		return []*core.Node{&root.left, &root.right}
+	case *InequalityNode:
+		return []*core.Node{&root.left, &root.right}
+	case *GreaterNode:
+		return []*core.Node{&root.left, &root.right}
+	case *GreaterOrEqualNode:
+		return []*core.Node{&root.left, &root.right}
+	case *LessNode:
+		return []*core.Node{&root.left, &root.right}
+	case *LessOrEqualNode:
+		return []*core.Node{&root.left, &root.right}
+	}
+	return nil
+}
+
+func fields(nodes []core.Node) []*core.Node {
+	res := make([]*core.Node, len(nodes))
+	for i := range nodes {
+		res[i] = &nodes[i]
+	}
+	return res
+}
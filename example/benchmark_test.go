@@ -0,0 +1,30 @@
+package example_test
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"testing"
+)
+
+const fibonacciBenchmark = `func int fibonacci(int n)
+	if n < 2
+		return n
+	return fibonacci(n - 1) + fibonacci(n - 2)
+int result = fibonacci(30)
+`
+
+func BenchmarkRecursiveFibonacci(b *testing.B) {
+	b.Run("TreeWalker", func(b *testing.B) {
+		benchmarkRecursiveFibonacci(b, runner.TreeWalker)
+	})
+	b.Run("VirtualMachine", func(b *testing.B) {
+		benchmarkRecursiveFibonacci(b, runner.VirtualMachine)
+	})
+}
+
+func benchmarkRecursiveFibonacci(b *testing.B, backend runner.Backend) {
+	for i := 0; i < b.N; i++ {
+		if code := runner.Run("fibonacci_benchmark.selinus", fibonacciBenchmark, runner.WithBackend(backend)); code != 0 {
+			b.Fatal("output code is ", code)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package example_test
+
+import (
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func newInterpreter(t *testing.T, opts ...runner.Option) *runner.Interpreter {
+	interpreter, err := runner.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return interpreter
+}
+
+func TestInterpreterCall(t *testing.T) {
+	for _, backend := range backends {
+		interpreter := newInterpreter(t, runner.WithBackend(backend))
+		err := interpreter.Eval("func int add(int a, int b)\n\treturn a + b\nfunc string greet(string name)\n\treturn \"hello \" + name\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Declarations of earlier evaluations are visible to later ones.
+		err = interpreter.Eval("func bigint square(bigint n)\n\treturn n * n\nint three = add(1, 2)\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := interpreter.Call("add", 2, 3); err != nil || res != int64(5) {
+			t.Fatal("add(2, 3) returned ", res, err)
+		}
+		if res, err := interpreter.Call("greet", "world"); err != nil || res != "hello world" {
+			t.Fatal("greet(\"world\") returned ", res, err)
+		}
+		res, err := interpreter.Call("square", big.NewInt(1<<40))
+		if err != nil || res.(*big.Int).Cmp(new(big.Int).Lsh(big.NewInt(1), 80)) != 0 {
+			t.Fatal("square(2^40) returned ", res, err)
+		}
+		if _, err := interpreter.Call("add", "two", 3); err == nil {
+			t.Fatal("expected an error for an argument of the wrong type")
+		}
+		if _, err := interpreter.Call("missing"); err == nil {
+			t.Fatal("expected an error for an undeclared function")
+		}
+	}
+}
+
+func TestInterpreterCompiledStandardLibrary(t *testing.T) {
+	// Every backend loads the compiled standard library instead of compiling its source again.
+	for _, backend := range backends {
+		value, _, err := newInterpreter(t, runner.WithBackend(backend)).Evaluate("println\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := value.Variable.VariableInterface.(*vm.Closure); !ok {
+			t.Fatalf("println is a %T", value.Variable.VariableInterface)
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interpreter := newInterpreter(t)
+	var scanError *runner.ScanError
+	if err := interpreter.Eval("int x = 1 $ 2\n"); !errors.As(err, &scanError) {
+		t.Fatal("expected a scan error, got ", err)
+	}
+	var parseError *runner.ParseError
+	if err := interpreter.Eval("int x = (1\n"); !errors.As(err, &parseError) {
+		t.Fatal("expected a parse error, got ", err)
+	}
+	// Statements the parser can not handle are reported instead of crashing the host.
+	if err := interpreter.Eval("int q = 1\nq++\n"); !errors.As(err, &parseError) {
+		t.Fatal("expected a parse error, got ", err)
+	}
+	if _, _, err := interpreter.Evaluate("int q = 1\nq++\n"); !errors.As(err, &parseError) {
+		t.Fatal("expected a parse error, got ", err)
+	}
+	if _, err := interpreter.TypeOf("q++\n"); !errors.As(err, &parseError) {
+		t.Fatal("expected a parse error, got ", err)
+	}
+	var compileError *runner.CompileError
+	if err := interpreter.Eval("int x = 1\nprint = println\n"); !errors.As(err, &compileError) {
+		t.Fatal("expected a compile error, got ", err)
+	}
+	// The declarations of a program that does not compile are forgotten.
+	if err := interpreter.Eval("x = 2\n"); !errors.As(err, &compileError) {
+		t.Fatal("expected x to be undeclared, got ", err)
+	}
+	var runtimeError *runner.RuntimeError
+	if err := interpreter.Eval("func int divide(int a, int b)\n\treturn a / b\nint y = divide(1, 0)\n"); !errors.As(err, &runtimeError) {
+		t.Fatal("expected a runtime error, got ", err)
+	}
+	if runtimeError.Message != "division by zero" || runtimeError.Positions[0] != "/ at line 2 position 11 at file eval" {
+		t.Fatal("unexpected runtime error ", runtimeError.Message, runtimeError.Positions)
+	}
+	if _, err := interpreter.Call("divide", 1, 0); !errors.As(err, &runtimeError) {
+		t.Fatal("expected a runtime error, got ", err)
+	}
+	if err := interpreter.Eval("let c = channel(int)\nint z = int(receive(c))\n"); !errors.As(err, &runtimeError) || runtimeError.Message != "deadlock, every task is waiting for another one" {
+		t.Fatal("expected a deadlock, got ", err)
+	}
+}
+
+func TestInterpreterLimitsPerEvaluation(t *testing.T) {
+	interpreter := newInterpreter(t, runner.WithMaxSteps(1000))
+	for i := 0; i < 3; i++ {
+		if err := interpreter.Eval("loop 1 to 900 as i\n\tint x = i\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var runtimeError *runner.RuntimeError
+	if err := interpreter.Eval("loop 1 to 1100 as i\n\tint x = i\n"); !errors.As(err, &runtimeError) || runtimeError.Message != "step limit of 1000 exceeded" {
+		t.Fatal("expected the step limit to be exceeded, got ", err)
+	}
+}
+
+func TestInterpreterRegister(t *testing.T) {
+	for _, backend := range backends {
+		interpreter := newInterpreter(t, runner.WithBackend(backend))
+		err := interpreter.Register("repeat", func(n int64, s string) (string, error) {
+			if n < 0 {
+				return "", errors.New("negative count")
+			}
+			return strings.Repeat(s, int(n)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := interpreter.Register("repeat", strings.Repeat); err == nil {
+			t.Fatal("expected repeat to be declared already")
+		}
+		if err := interpreter.Eval("func string twice(string s)\n\treturn repeat(2, s)\n"); err != nil {
+			t.Fatal(err)
+		}
+		if res, err := interpreter.Call("twice", "ab"); err != nil || res != "abab" {
+			t.Fatal("twice(\"ab\") returned ", res, err)
+		}
+		var runtimeError *runner.RuntimeError
+		if err := interpreter.Eval("string s = repeat(-1, \"ab\")\n"); !errors.As(err, &runtimeError) || runtimeError.Message != "repeat: negative count" {
+			t.Fatal("expected the error of repeat to be raised, got ", err)
+		}
+		var compileError *runner.CompileError
+		if err := interpreter.Eval("string s = repeat(\"ab\", 2)\n"); !errors.As(err, &compileError) {
+			t.Fatal("expected a compile error for arguments of the wrong type, got ", err)
+		}
+	}
+}
+
+func TestInterpreterStreams(t *testing.T) {
+	for _, backend := range backends {
+		stdout, stderr := &strings.Builder{}, &strings.Builder{}
+		interpreter := newInterpreter(t, runner.WithBackend(backend), runner.WithStdin(strings.NewReader("20\n22\n")), runner.WithStdout(stdout), runner.WithStderr(stderr))
+		if err := interpreter.Eval("int a = scanInteger()\nprintln(string(a))\n"); err != nil {
+			t.Fatal(err)
+		}
+		// Input buffered by one evaluation is not lost for the next one.
+		if err := interpreter.Eval("int b = scanInteger()\nprintln(string(a + b))\neprintln(\"done\")\n"); err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != "20\n42\n" || stderr.String() != "done\n" {
+			t.Fatalf("unexpected output %q and error output %q", stdout.String(), stderr.String())
+		}
+		var runtimeError *runner.RuntimeError
+		if err := interpreter.Eval("int c = scanInteger()\n"); !errors.As(err, &runtimeError) || runtimeError.Message != "scanInteger: EOF" {
+			t.Fatal("expected reading past the end of the input to fail, got ", err)
+		}
+	}
+}
+
+func TestInterpreterEvaluate(t *testing.T) {
+	for _, backend := range backends {
+		interpreter := newInterpreter(t, runner.WithBackend(backend))
+		value, typ, err := interpreter.Evaluate("func int square(int n)\n\treturn n * n\nsquare(7) + 1\n")
+		if err != nil || typ == nil || typ.Name != "Integer" || builtin.GoValueOf(value) != int64(50) {
+			t.Fatal("square(7) + 1 evaluated to ", value, typ, err)
+		}
+		if _, typ, err := interpreter.Evaluate("int x = square(2)\n"); err != nil || typ != nil {
+			t.Fatal("a declaration has no value but got ", typ, err)
+		}
+		if typ, err := interpreter.TypeOf("\"x is \" + x\n"); err != nil || typ.Name != "String" {
+			t.Fatal("unexpected type ", typ, err)
+		}
+		if typ, err := interpreter.TypeOf("int y = x\n"); err != nil || typ != nil {
+			t.Fatal("a declaration has no type but got ", typ, err)
+		}
+		if _, _, err := interpreter.Evaluate("y\n"); err == nil {
+			t.Fatal("TypeOf declared y")
+		}
+		var runtimeError *runner.RuntimeError
+		if _, _, err := interpreter.Evaluate("x / 0\n"); !errors.As(err, &runtimeError) || runtimeError.Positions[0] != "/ at line 1 position 3 at file eval" || len(runtimeError.Positions) != 1 {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+}
@@ -1,11 +1,24 @@
 package example_test
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
-	"github.com/cevatbarisyilmaz/selinus/library/standard/native"
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/format"
+	"github.com/cevatbarisyilmaz/selinus/library/standard"
+	"github.com/cevatbarisyilmaz/selinus/parser"
 	"github.com/cevatbarisyilmaz/selinus/runner"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 //go:embed files/helloworld.selinus
@@ -14,6 +27,33 @@ var helloWorldTest string
 //go:embed files/recursive_fibonacci.selinus
 var recursiveFibonacciTest string
 
+//go:embed files/big_integer.selinus
+var bigIntegerTest string
+
+//go:embed files/comparison.selinus
+var comparisonTest string
+
+//go:embed files/conversion.selinus
+var conversionTest string
+
+//go:embed files/indentation.selinus
+var indentationTest string
+
+//go:embed files/constants.selinus
+var constantsTest string
+
+//go:embed files/branches.selinus
+var branchesTest string
+
+//go:embed files/folding.selinus
+var foldingTest string
+
+//go:embed files/tail_calls.selinus
+var tailCallsTest string
+
+//go:embed files/concurrency.selinus
+var concurrencyTest string
+
 var examples = []*struct {
 	testFileContent string
 	testFilePath    string
@@ -29,13 +69,402 @@ var examples = []*struct {
 		testFilePath:    "recursive_fibonacci.selinus",
 		expectedOutput:  "Fibonacci 0: 0\nFibonacci 1: 1\nFibonacci 2: 1\nFibonacci 3: 2\nFibonacci 4: 3\nFibonacci 5: 5\nFibonacci 6: 8\nFibonacci 7: 13\nFibonacci 8: 21\nFibonacci 9: 34\nFibonacci 10: 55\n",
 	},
+	{
+		testFileContent: bigIntegerTest,
+		testFilePath:    "big_integer.selinus",
+		expectedOutput:  "Fibonacci 100: 354224848179261915075\n30! = 265252859812191058636308480000000\n30! / 10^30 = 265\n",
+	},
+	{
+		testFileContent: comparisonTest,
+		testFilePath:    "comparison.selinus",
+		expectedOutput:  "hi bob\napple first\nnot alice\nbools\nsets\nsets differ\nge\n",
+	},
+	{
+		testFileContent: conversionTest,
+		testFilePath:    "conversion.selinus",
+		expectedOutput:  "short-circuit or\nevaluated true\nevaluated true\nboth\n42true\n124\ntruthy\n1\n246913578024691357802469135780\n123\n",
+	},
+	{
+		testFileContent: indentationTest,
+		testFilePath:    "indentation.selinus",
+		expectedOutput:  "twice 1\ntwice 1: 2\ntwice 2\ntwice 2: 4\ntwice 3\ntwice 3: 6\nspaces\nnested\ndone\n",
+	},
+	{
+		testFileContent: constantsTest,
+		testFilePath:    "constants.selinus",
+		expectedOutput:  "Hello, constants\nlimit: 5 doubled: 10 big: 500\nsquare 1\nsquare 4\nsquare 9\nsquare 16\nsquare 25\n",
+	},
+	{
+		testFileContent: branchesTest,
+		testFilePath:    "branches.selinus",
+		expectedOutput:  "-1 is negative\n0 is zero\n1 is positive\n",
+	},
+	{
+		testFileContent: foldingTest,
+		testFilePath:    "folding.selinus",
+		expectedOutput:  "Fibonacci 1 6 true\nfolded else\nfolded if\n42\n21\nidentity or\nidentity and\n",
+	},
+	{
+		testFileContent: tailCallsTest,
+		testFilePath:    "tail_calls.selinus",
+		expectedOutput:  "count: 100000\n25! = 15511210043330985984000000\ndepth: 1000\n",
+	},
+	{
+		testFileContent: concurrencyTest,
+		testFilePath:    "concurrency.selinus",
+		expectedOutput:  "sum of squares: 385\nnothing is ready\nreceived true\ndone\n",
+	},
+	{
+		testFileContent: "func pass(chan c)\n\tselect\n\t\tsend(c, 1)\n\t\t\tint sent = 1\nlet c = channel(int)\nspawn pass(c)\nselect\n\treceive(c) as v\n\t\tprintln(\"received \" + v)\njoin\n",
+		testFilePath:    "select_to_select.selinus",
+		expectedOutput:  "received 1\n",
+	},
+	{
+		testFileContent: "int x = 1 +\n  2 *\n  3\nprintln(string(x))\n",
+		testFilePath:    "continuation.selinus",
+		expectedOutput:  "7\n",
+	},
+}
+
+var compileErrors = []*struct {
+	testFileContent string
+	testFilePath    string
+	expectedError   string
+}{
+	{
+		testFileContent: "const x = 1\nx = 2\n",
+		testFilePath:    "assign_constant.selinus",
+		expectedError:   "Compile error: cannot assign to immutable x at line",
+	},
+	{
+		testFileContent: "let x = 1\nx = 2\n",
+		testFilePath:    "assign_let.selinus",
+		expectedError:   "Compile error: cannot assign to immutable x at line",
+	},
+	{
+		testFileContent: "print = println\n",
+		testFilePath:    "assign_builtin_function.selinus",
+		expectedError:   "Compile error: cannot assign to immutable print at line",
+	},
+	{
+		testFileContent: "int = 3\n",
+		testFilePath:    "assign_builtin_type.selinus",
+		expectedError:   "Compile error: cannot assign to immutable int at line",
+	},
+	{
+		testFileContent: "int y = 2\nconst x = y\n",
+		testFilePath:    "non_constant_initializer.selinus",
+		expectedError:   "Compile error: initializer of constant x is not a constant expression",
+	},
+	{
+		testFileContent: "int x\nprintln(string(x))\n",
+		testFilePath:    "unassigned.selinus",
+		expectedError:   "Compile error: x might be used before it is assigned x at line 2 position 16",
+	},
+	{
+		testFileContent: "int x\nif 1 < 2\n\tx = 1\nprintln(string(x))\n",
+		testFilePath:    "unassigned_branch.selinus",
+		expectedError:   "Compile error: x might be used before it is assigned x at line 4 position 16",
+	},
+	{
+		testFileContent: "int x\nloop 1 to 3 as i\n\tx = i\nprintln(string(x))\n",
+		testFilePath:    "unassigned_loop.selinus",
+		expectedError:   "Compile error: x might be used before it is assigned x at line 4 position 16",
+	},
+	{
+		testFileContent: "func int f(int n)\n\tif n < 0\n\t\treturn 0\n",
+		testFilePath:    "missing_return.selinus",
+		expectedError:   "Compile error: expected return statement at the end of function f",
+	},
+	{
+		testFileContent: "int x = \"a\"\n",
+		testFilePath:    "incompatible_assignment.selinus",
+		expectedError:   "Compile error: incompatible types",
+	},
+	{
+		testFileContent: "func f()\n\tprintln(\"a\")\nint x = f() + 1\n",
+		testFilePath:    "void_operand.selinus",
+		expectedError:   "Compile error: operand does not return a variable for operation +",
+	},
+	{
+		testFileContent: "let c = channel(int)\nsend(c, \"a\")\n",
+		testFilePath:    "send_incompatible.selinus",
+		expectedError:   "Compile error: channel of Integer can not hold String",
+	},
+	{
+		testFileContent: "int x = receive(1)\n",
+		testFilePath:    "receive_not_channel.selinus",
+		expectedError:   "Compile error: expected a channel",
+	},
+	{
+		testFileContent: "let f = send\n",
+		testFilePath:    "intrinsic_value.selinus",
+		expectedError:   "Compile error: send can only be called",
+	},
+	{
+		testFileContent: "spawn 1 + 2\n",
+		testFilePath:    "spawn_expression.selinus",
+		expectedError:   "Parsing error: expected a function call after spawn",
+	},
+	{
+		testFileContent: "let c = channel(int)\nselect\n\tprintln(\"a\")\n",
+		testFilePath:    "select_call.selinus",
+		expectedError:   "Compile error: expected receive or send",
+	},
+	{
+		testFileContent: "let c = channel(int)\nselect\n\tsend(c, 1) as x\n",
+		testFilePath:    "select_send_binding.selinus",
+		expectedError:   "Compile error: send does not receive a value",
+	},
+	{
+		testFileContent: "func int f(chan c)\n\tselect\n\t\treceive(c) as x\n\t\t\treturn int(x)\n\t\telse\n\t\t\tprintln(\"none\")\n",
+		testFilePath:    "select_missing_return.selinus",
+		expectedError:   "Compile error: expected return statement at the end of function f",
+	},
+	{
+		testFileContent: "const a = 1\nint a = 2\nprintln(\"\" + a)\n",
+		testFilePath:    "redeclare_constant.selinus",
+		expectedError:   "Compile error: a is already declared a at line 2 position 5",
+	},
+	{
+		testFileContent: "let a = 1\nif true\n\tint a = 2\n\tprintln(\"\" + a)\nint a, int b = 1, 2\n",
+		testFilePath:    "redeclare_let.selinus",
+		expectedError:   "Compile error: a is already declared a at line 5 position 5",
+	},
+	{
+		testFileContent: "if !true\n\tprintln(\"a\")\n",
+		testFilePath:    "not_operator.selinus",
+		expectedError:   "Scanning error: unknown operator ! at line 1 position 4",
+	},
+	{
+		testFileContent: "bool b = 1 != 2\nprintln(string(b ! 1))\n",
+		testFilePath:    "not_operator_after_operand.selinus",
+		expectedError:   "Scanning error: unknown operator ! at line 2 position 18",
+	},
+	{
+		testFileContent: "func greet()\n\tprintln(\"hi\")\n\treturn\ngreet()\n",
+		testFilePath:    "bare_return.selinus",
+		expectedError:   "Compile error: unexpected return statement return at line 3 position 2",
+	},
+	{
+		testFileContent: "int q = 1\nq++\n",
+		testFilePath:    "increase.selinus",
+		expectedError:   "Parsing error: unexpected token ++ at line 2 position 2",
+	},
+	{
+		testFileContent: "int q = 1\nprintln(string(q--1))\n",
+		testFilePath:    "decrease.selinus",
+		expectedError:   "Parsing error: unexpected token -- at line 2 position 17",
+	},
 }
 
+var exceptions = []*struct {
+	testFileContent string
+	testFilePath    string
+	expectedMessage string
+}{
+	{
+		testFileContent: "int x\nfunc show()\n\tprintln(string(x))\nshow()\n",
+		testFilePath:    "uninitialized_capture.selinus",
+		expectedMessage: "uninitialized variable x",
+	},
+	{
+		testFileContent: "var v = \"a\"\nprintln(string(int(v) + 1))\n",
+		testFilePath:    "var_conversion.selinus",
+		expectedMessage: "cannot convert \"a\" to Integer",
+	},
+	{
+		testFileContent: "var v = \"a\"\nif v < 3\n\tprintln(\"less\")\n",
+		testFilePath:    "var_comparison.selinus",
+		expectedMessage: "cannot compare String with Integer",
+	},
+	{
+		testFileContent: "var v = 1, 2\nprintln(string(v))\n",
+		testFilePath:    "var_set_conversion.selinus",
+		expectedMessage: "conversion from Set to String is not possible",
+	},
+	{
+		testFileContent: "func int divide(int a, int b)\n\treturn a / b\nloop 1 to 3 as i\n\tif i == 3\n\t\tprintln(\"result \" + divide(i, 0))\n",
+		testFilePath:    "nested_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "bigint zero = 0n\nbool b = 1 < 2 && 10n / zero > 1\n",
+		testFilePath:    "big_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "println(string(1 + 2 / 0))\n",
+		testFilePath:    "constant_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "func int depth(int n)\n\treturn depth(n + 1) + 1\nprintln(string(depth(0)))\n",
+		testFilePath:    "stack_overflow.selinus",
+		expectedMessage: "stack overflow, call depth limit of 10000 exceeded",
+	},
+	{
+		testFileContent: "func int f(int n)\n\tif n == 0\n\t\treturn 1 / n\n\treturn f(n - 1)\nprintln(string(f(3)))\n",
+		testFilePath:    "tail_call_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "func fail(int n)\n\tprintln(string(10 / n))\nspawn fail(0)\njoin\nprintln(\"joined\")\n",
+		testFilePath:    "task_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "func fail(int n)\n\tprintln(string(10 / n))\nspawn fail(0)\n",
+		testFilePath:    "unjoined_task_division_by_zero.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "let c = channel(int)\nfunc wait()\n\tprintln(string(receive(c)))\nspawn wait()\nprintln(string(1 / 0))\n",
+		testFilePath:    "blocked_task.selinus",
+		expectedMessage: "division by zero",
+	},
+	{
+		testFileContent: "let c = channel(int, 1)\nsend(c, 1)\nclose(c)\nprintln(string(receive(c)))\nprintln(string(receive(c)))\n",
+		testFilePath:    "receive_closed.selinus",
+		expectedMessage: "receive from closed channel",
+	},
+	{
+		testFileContent: "let c = channel(int)\nclose(c)\nselect\n\tsend(c, 1)\n",
+		testFilePath:    "select_send_closed.selinus",
+		expectedMessage: "send on closed channel",
+	},
+	{
+		testFileContent: "let c = channel(int, -1)\n",
+		testFilePath:    "negative_channel_size.selinus",
+		expectedMessage: "negative channel size -1",
+	},
+}
+
+var backends = []runner.Backend{runner.TreeWalker, runner.VirtualMachine}
+
 func TestExamples(t *testing.T) {
 	builder := &strings.Builder{}
-	native.SetDefaultOutputWriter(builder)
+	for _, backend := range backends {
+		for _, example := range examples {
+			code := runner.Run(example.testFilePath, example.testFileContent, runner.WithBackend(backend), runner.WithStdout(builder))
+			if code != 0 {
+				t.Fatal(example.testFilePath, " output code is ", code)
+			}
+			output := builder.String()
+			if output != example.expectedOutput {
+				t.Fatalf("output mismatch, expected: %s, got: %s", example.expectedOutput, output)
+			}
+			builder.Reset()
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, backend := range backends {
+		for _, example := range compileErrors {
+			interpreter, err := runner.New(runner.WithBackend(backend))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = interpreter.Eval(example.testFileContent)
+			if err == nil || !strings.HasPrefix(err.Error(), example.expectedError) {
+				t.Fatalf("%s was expected to fail with %q, got: %v", example.testFilePath, example.expectedError, err)
+			}
+		}
+	}
+}
+
+// runProgram runs a program with runner.Run, discarding its output, and returns the exit code and what was written to
+// the standard error, which is where Run reports errors and exceptions.
+func runProgram(filePath, fileContent string, opts ...runner.Option) (int, string) {
+	stderr := &strings.Builder{}
+	code := runner.Run(filePath, fileContent, append(opts, runner.WithStdout(io.Discard), runner.WithStderr(stderr))...)
+	return code, stderr.String()
+}
+
+func TestExceptions(t *testing.T) {
+	for _, example := range exceptions {
+		var traces []string
+		for _, backend := range backends {
+			code, trace := runProgram(example.testFilePath, example.testFileContent, runner.WithBackend(backend))
+			if code == 0 {
+				t.Error(example.testFilePath, " was expected to raise an exception")
+			}
+			traces = append(traces, trace)
+		}
+		if traces[0] != traces[1] {
+			t.Fatalf("stack traces of %s differ between backends:\n%s\n%s", example.testFilePath, traces[0], traces[1])
+		}
+		if !strings.HasPrefix(traces[0], example.expectedMessage+"\n") {
+			t.Fatalf("%s was expected to raise %q, got:\n%s", example.testFilePath, example.expectedMessage, traces[0])
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	program := "func int depth(int n)\n\tif n == 0\n\t\treturn 0\n\treturn depth(n - 1) + 1\nprintln(string(depth(20)))\n"
+	for _, backend := range backends {
+		code, output := runProgram("max_call_depth.selinus", program, runner.WithBackend(backend), runner.WithMaxCallDepth(10))
+		if code == 0 {
+			t.Error("call depth limit was not enforced")
+		}
+		if !strings.HasPrefix(output, "stack overflow, call depth limit of 10 exceeded\n") {
+			t.Fatal("unexpected output ", output)
+		}
+	}
+}
+
+// runLimited runs a program that does not terminate on its own with each backend and checks that it is stopped with
+// the expected exception.
+func runLimited(t *testing.T, program string, message string, opts ...runner.Option) {
+	for _, backend := range backends {
+		code, output := runProgram("limited.selinus", program, append(opts, runner.WithBackend(backend))...)
+		if code == 0 {
+			t.Error("program was not stopped")
+		}
+		if !strings.HasPrefix(output, message+"\n") {
+			t.Fatal("unexpected output ", output)
+		}
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	runLimited(t, "loop 1 to 1000000000000 as i\n\tint x = i\n", "step limit of 1000 exceeded", runner.WithMaxSteps(1000))
+	runLimited(t, "func int spin(int n)\n\treturn spin(n + 1)\nprintln(string(spin(0)))\n", "step limit of 1000 exceeded", runner.WithMaxSteps(1000))
+}
+
+func TestMaxMemory(t *testing.T) {
+	runLimited(t, "string s = \"a\"\nloop 1 to 100 as i\n\ts = s + s\n", "memory limit of 1000000 bytes exceeded", runner.WithMaxMemory(1000000))
+	runLimited(t, "bigint b = 2n\nloop 1 to 100 as i\n\tb = b * b\n", "memory limit of 1000000 bytes exceeded", runner.WithMaxMemory(1000000))
+}
+
+func TestTimeout(t *testing.T) {
+	runLimited(t, "func int spin(int n)\n\treturn spin(n + 1)\nprintln(string(spin(0)))\n", "execution cancelled: context deadline exceeded", runner.WithTimeout(50*time.Millisecond))
+	runLimited(t, "func spin(int n)\n\tloop 1 to n as i\n\t\tint x = i\nlet c = channel(int)\nspawn spin(1000000000000)\nprintln(string(receive(c)))\n", "execution cancelled: context deadline exceeded", runner.WithTimeout(50*time.Millisecond))
+	input, _ := io.Pipe()
+	runLimited(t, "println(string(scanInteger()))\n", "execution cancelled: context deadline exceeded", runner.WithTimeout(50*time.Millisecond), runner.WithStdin(input))
+	runLimited(t, "func spin(int n)\n\tloop 1 to n as i\n\t\tint x = i\nspawn spin(1000000000000)\n", "execution cancelled: context deadline exceeded", runner.WithTimeout(50*time.Millisecond))
+}
+
+func TestCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runLimited(t, "loop 1 to 1000000000000 as i\n\tint x = i\n", "execution cancelled: context canceled", runner.WithContext(ctx))
+}
+
+// build compiles the example to a file in a temporary directory and returns its path.
+func build(t *testing.T, filePath, fileContent string) string {
+	outputPath := filepath.Join(t.TempDir(), strings.TrimSuffix(filePath, ".selinus")+".selc")
+	if code := runner.Build(filePath, fileContent, outputPath); code != 0 {
+		t.Fatal(filePath, " could not be built")
+	}
+	return outputPath
+}
+
+func TestCompiledExamples(t *testing.T) {
+	builder := &strings.Builder{}
 	for _, example := range examples {
-		code := runner.Run(example.testFilePath, example.testFileContent)
+		code := runner.Run(build(t, example.testFilePath, example.testFileContent), "", runner.WithStdout(builder))
 		if code != 0 {
 			t.Fatal(example.testFilePath, " output code is ", code)
 		}
@@ -46,3 +475,427 @@ func TestExamples(t *testing.T) {
 		builder.Reset()
 	}
 }
+
+func TestCompiledExceptions(t *testing.T) {
+	for _, example := range exceptions {
+		compiledPath := build(t, example.testFilePath, example.testFileContent)
+		_, expected := runProgram(example.testFilePath, example.testFileContent, runner.WithBackend(runner.VirtualMachine))
+		code, trace := runProgram(compiledPath, "")
+		if code == 0 {
+			t.Error(example.testFilePath, " was expected to raise an exception")
+		}
+		if trace != expected {
+			t.Fatalf("stack traces of compiled %s differ:\n%s\n%s", example.testFilePath, expected, trace)
+		}
+	}
+}
+
+func TestCorruptedCompiledProgram(t *testing.T) {
+	compiledPath := build(t, "helloworld.selinus", helloWorldTest)
+	data, err := os.ReadFile(compiledPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-1]++
+	outdated := append([]byte(nil), data...)
+	outdated[5]++
+	for _, data := range [][]byte{corrupted, outdated, data[:len(data)/2]} {
+		if err := os.WriteFile(compiledPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		code, output := runProgram(compiledPath, "")
+		if code == 0 {
+			t.Error("invalid program was loaded")
+		}
+		if !strings.HasPrefix(output, "Load error") {
+			t.Fatal("unexpected output ", output)
+		}
+	}
+}
+
+func TestInvalidInstructions(t *testing.T) {
+	for _, instructions := range [][]vm.Instruction{
+		{{Opcode: vm.OpSelectCase + 1, Origin: -1}},
+		{{Opcode: vm.OpConstant, A: 1, Origin: -1}},
+		{{Opcode: vm.OpJump, A: 2, Origin: -1}},
+		{{Opcode: vm.OpDeclare, Origin: -1}},
+		{{Opcode: vm.OpSelect, A: 1, C: -1, Origin: -1}, {Opcode: vm.OpPop, Origin: -1}},
+		{{Opcode: vm.OpPop, Origin: 1}},
+	} {
+		program := &vm.Program{Main: &vm.Prototype{Name: "main", Code: instructions}, Constants: []*core.Pointer{nil}}
+		data, err := vm.Encode(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compiledPath := filepath.Join(t.TempDir(), "invalid.selc")
+		if err := os.WriteFile(compiledPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		code, output := runProgram(compiledPath, "")
+		if code == 0 {
+			t.Error("invalid instructions were loaded")
+		}
+		if !regexp.MustCompile(`^Load error.*(invalid operands|unknown opcode)`).MatchString(output) {
+			t.Fatal("unexpected output ", output)
+		}
+	}
+}
+
+func TestCompiledStandardLibrary(t *testing.T) {
+	data, err := runner.BuildModule(standard.Module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, standard.Module.Compiled) {
+		t.Fatal("compiled standard library is outdated, run go generate ./library/standard")
+	}
+}
+
+// TestConcurrentExamples runs the examples in parallel goroutines, run it with -race to detect state shared between
+// runs.
+func TestConcurrentExamples(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, backend := range backends {
+		for _, example := range examples {
+			backend, example := backend, example
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					output := &strings.Builder{}
+					interpreter, err := runner.New(runner.WithBackend(backend), runner.WithStdout(output))
+					if err == nil {
+						err = interpreter.Eval(example.testFileContent)
+					}
+					if err != nil {
+						t.Error(example.testFilePath, ": ", err)
+					} else if output.String() != example.expectedOutput {
+						t.Errorf("output mismatch, expected: %s, got: %s", example.expectedOutput, output.String())
+					}
+				}()
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// TestTasks runs tasks that share the output of the program, run it with -race to detect state shared between them.
+func TestTasks(t *testing.T) {
+	program := "func greet(int i)\n\tprintln(\"task \" + i)\nloop 1 to 50 as i\n\tspawn greet(i)\njoin\nprintln(\"joined\")\n"
+	for _, backend := range backends {
+		output := &strings.Builder{}
+		if code := runner.Run("tasks.selinus", program, runner.WithBackend(backend), runner.WithStdout(output)); code != 0 {
+			t.Fatal("tasks.selinus output code is ", code)
+		}
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		if len(lines) != 51 || lines[50] != "joined" {
+			t.Fatal("unexpected output ", output.String())
+		}
+		seen := make(map[string]bool)
+		for _, line := range lines[:50] {
+			seen[line] = true
+		}
+		if len(seen) != 50 {
+			t.Fatal("tasks did not all print once ", output.String())
+		}
+	}
+}
+
+func TestScheduledExamples(t *testing.T) {
+	builder := &strings.Builder{}
+	for _, backend := range backends {
+		for _, example := range examples {
+			for seed := int64(1); seed <= 3; seed++ {
+				code := runner.Run(example.testFilePath, example.testFileContent, runner.WithBackend(backend), runner.WithStdout(builder), runner.WithSeed(seed))
+				if code != 0 {
+					t.Fatal(example.testFilePath, " output code is ", code, " with seed ", seed)
+				}
+				if output := builder.String(); output != example.expectedOutput {
+					t.Fatalf("output mismatch with seed %d, expected: %s, got: %s", seed, example.expectedOutput, output)
+				}
+				builder.Reset()
+			}
+		}
+	}
+}
+
+func TestScheduledExceptions(t *testing.T) {
+	for _, example := range exceptions {
+		for _, backend := range backends {
+			code, output := runProgram(example.testFilePath, example.testFileContent, runner.WithBackend(backend), runner.WithSeed(7))
+			if code == 0 {
+				t.Error(example.testFilePath, " was expected to raise an exception")
+			}
+			if !strings.HasSuffix(output, "\nreplay with seed 7\n") {
+				t.Fatal("seed is missing from the output ", output)
+			}
+		}
+	}
+}
+
+// TestReplay checks that the order in which tasks run depends only on the seed.
+func TestReplay(t *testing.T) {
+	program := "func greet(int i)\n\tloop 1 to 3 as j\n\t\tprintln(\"task \" + i + \" step \" + j)\nloop 1 to 20 as i\n\tspawn greet(i)\njoin\n"
+	for _, backend := range backends {
+		outputs := make(map[int64]string)
+		for _, seed := range []int64{1, 2, 1, 2} {
+			output := &strings.Builder{}
+			if code := runner.Run("replay.selinus", program, runner.WithBackend(backend), runner.WithStdout(output), runner.WithSeed(seed)); code != 0 {
+				t.Fatal("replay.selinus output code is ", code)
+			}
+			if previous, ok := outputs[seed]; ok && previous != output.String() {
+				t.Fatalf("seed %d was not replayed:\n%s\n%s", seed, previous, output.String())
+			}
+			outputs[seed] = output.String()
+		}
+		if outputs[1] == outputs[2] {
+			t.Fatal("tasks ran in the same order with different seeds")
+		}
+	}
+}
+
+// deadlocks are programs whose tasks end up waiting for each other, which is reported whether they run in parallel or
+// on a scheduler.
+var deadlocks = []string{
+	"let c = channel(int)\nprintln(string(receive(c)))\n",
+	"let c = channel(int, 1)\nsend(c, 1)\nsend(c, 2)\n",
+	"func relay(chan source, chan target)\n\tsend(target, int(receive(source)))\nlet a = channel(int)\nlet b = channel(int)\nspawn relay(a, b)\nspawn relay(b, a)\njoin\n",
+	"let c = channel(int)\nfunc wait()\n\tprintln(string(receive(c)))\nspawn wait()\n",
+	"let c = channel(int)\nfunc leave()\n\tprintln(\"leaving\")\nspawn leave()\nprintln(string(receive(c)))\n",
+	"let a = channel(int)\nlet b = channel(bool)\nselect\n\treceive(a) as x\n\t\tprintln(\"a\")\n\tsend(b, true)\n\t\tprintln(\"b\")\n",
+}
+
+func TestDeadlock(t *testing.T) {
+	for _, program := range deadlocks {
+		runLimited(t, program, "deadlock, every task is waiting for another one", runner.WithTimeout(10*time.Second))
+		runLimited(t, program, "deadlock, every task is waiting for another one", runner.WithSeed(1))
+	}
+	runLimited(t, "func spin(int n)\n\tloop 1 to n as i\n\t\tint x = i\nlet c = channel(int)\nspawn spin(1000000000000)\nprintln(string(receive(c)))\n", "execution cancelled: context deadline exceeded", runner.WithSeed(1), runner.WithTimeout(50*time.Millisecond))
+	// The exception of a task that never sends is reported rather than the deadlock it causes.
+	for _, program := range []string{
+		"let c = channel(int)\nfunc produce()\n\tint x = 1 / 0\n\tsend(c, x)\nspawn produce()\nprintln(string(receive(c)))\n",
+		"let c = channel(int)\nfunc produce()\n\tint x = 1 / 0\n\tsend(c, x)\nfunc relay()\n\tspawn produce()\n\tprintln(string(receive(c)))\nspawn relay()\njoin\n",
+	} {
+		runLimited(t, program, "division by zero", runner.WithTimeout(10*time.Second))
+		runLimited(t, program, "division by zero", runner.WithSeed(1))
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	programs := []struct {
+		content string
+		code    int
+	}{
+		{"println(\"ok\")\n", runner.ExitSuccess},
+		{"int x = 1 / 0\n", runner.ExitException},
+		{"int x = \"a\"\n", runner.ExitCompileError},
+		{"int x = (\n", runner.ExitCompileError},
+	}
+	for _, program := range programs {
+		if code, _ := runProgram("exit.selinus", program.content); code != program.code {
+			t.Errorf("%q exited with %d, expected %d", program.content, code, program.code)
+		}
+	}
+	// Exceptions are reported on the standard error, apart from the output of the program.
+	stdout, stderr := &strings.Builder{}, &strings.Builder{}
+	runner.Run("exit.selinus", "println(\"out\")\nint x = 1 / 0\n", runner.WithStdout(stdout), runner.WithStderr(stderr))
+	if stdout.String() != "out\n" || !strings.HasPrefix(stderr.String(), "division by zero\n") {
+		t.Fatalf("unexpected output %q and error %q", stdout.String(), stderr.String())
+	}
+	if code, _ := runProgram(filepath.Join(t.TempDir(), "missing.selinus"), ""); code != runner.ExitError {
+		t.Errorf("missing file exited with %d, expected %d", code, runner.ExitError)
+	}
+	if code := runner.Check("check.selinus", "println(string(1 / 0))\n", io.Discard, false); code != runner.ExitSuccess {
+		t.Errorf("check ran the program, exited with %d", code)
+	}
+	if code := runner.Check("check.selinus", "int x = \"a\"\n", io.Discard, false); code != runner.ExitCompileError {
+		t.Errorf("check exited with %d, expected %d", code, runner.ExitCompileError)
+	}
+}
+
+func TestExitInTask(t *testing.T) {
+	// The spinning task is still running when the other one exits, the exit ends the program without waiting for it.
+	program := "let started = channel(bool)\nfunc spin()\n\tsend(started, true)\n\tloop 1 to 1000000000000 as i\n\t\tint x = i\nfunc quit()\n\treceive(started)\n\texit(3)\nspawn spin()\nspawn quit()\njoin\nprintln(\"unreachable\")\n"
+	for _, backend := range backends {
+		for _, opts := range [][]runner.Option{{}, {runner.WithSeed(1)}} {
+			opts = append(opts, runner.WithOS(), runner.WithBackend(backend), runner.WithTimeout(10*time.Second))
+			if code, output := runProgram("exit.selinus", program, opts...); code != 3 {
+				t.Fatalf("exited with %d, expected 3: %s", code, output)
+			}
+		}
+	}
+}
+
+func TestTestCommand(t *testing.T) {
+	directory := t.TempDir()
+	files := map[string]string{
+		"pass_test.selinus":        "println(\"pass\")\n",
+		"nested/fail_test.selinus": "println(\"before\")\nint x = 1 / 0\n",
+		"program.selinus":          "int x = 1 / 0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(directory, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := &strings.Builder{}
+	if code := runner.Test([]string{directory}, output); code != runner.ExitException {
+		t.Fatal("test exited with ", code)
+	}
+	if !strings.Contains(output.String(), "ok    "+filepath.Join(directory, "pass_test.selinus")) ||
+		!strings.Contains(output.String(), "FAIL  "+filepath.Join(directory, "nested", "fail_test.selinus")) ||
+		!strings.Contains(output.String(), "    division by zero\n") || !strings.Contains(output.String(), "    before\n") ||
+		strings.Contains(output.String(), "program.selinus") || strings.Contains(output.String(), "pass\n") {
+		t.Fatal("unexpected output ", output)
+	}
+	output.Reset()
+	if code := runner.Test([]string{filepath.Join(directory, "pass_test.selinus")}, output); code != runner.ExitSuccess {
+		t.Fatal("test exited with ", code, output)
+	}
+}
+
+func TestDump(t *testing.T) {
+	root, err := runner.Parse("dump.selinus", "func int double(int a)\n\treturn a * 2\nprintln(\"x\")\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Function func 1:1\n" +
+		"  identifier: double 1:10\n" +
+		"  return type: int 1:6\n" +
+		"  parameters:\n" +
+		"    Declaration int 1:17\n" +
+		"      identifier: a 1:21\n" +
+		"  children:\n" +
+		"    Return return 2:2\n" +
+		"      children:\n" +
+		"        Multiply * 2:11\n" +
+		"          children:\n" +
+		"            Variable a 2:9\n" +
+		"            Integer 2 2:13\n" +
+		"FunctionCall println 3:1\n" +
+		"  parameters:\n" +
+		"    String \"x\" 3:9\n"
+	if output := parser.Dump(root); output != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestOS(t *testing.T) {
+	t.Setenv("SELINUS_TEST", "value")
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := "println(string(args()))\nloop 1 to args() as i\n\tprintln(arg(i))\nprintln(env(\"SELINUS_TEST\"))\nprintln(cwd())\nexit(int(arg(1)))\nprintln(\"unreachable\")\n"
+	for _, backend := range backends {
+		output := &strings.Builder{}
+		if code := runner.Run("os.selinus", program, runner.WithOS(), runner.WithBackend(backend), runner.WithStdout(output), runner.WithArgs([]string{"7", "b"})); code != 7 {
+			t.Fatal("os.selinus output code is ", code)
+		}
+		if expected := "2\n7\nb\nvalue\n" + cwd + "\n"; output.String() != expected {
+			t.Fatalf("output mismatch, expected: %s, got: %s", expected, output)
+		}
+	}
+	runLimited(t, "println(arg(1))\n", "arg: index 1 is out of range, the program has 0 arguments", runner.WithOS())
+	runLimited(t, "exit(256)\n", "exit: code 256 is not between 0 and 255", runner.WithOS())
+	// Without WithOS, programs can not read the environment of their host.
+	for _, backend := range backends {
+		code, output := runProgram("os.selinus", "println(env(\"SELINUS_TEST\"))\n", runner.WithBackend(backend))
+		if code != runner.ExitCompileError || !strings.HasPrefix(output, "Compile error: function env is not defined") {
+			t.Fatal("env was declared without the os module: ", code, " ", output)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	programs := []struct {
+		content string
+		code    int
+	}{
+		{"exit(0)\nint x = 1 / 0\n", 0},
+		{"func f()\n\texit(4)\nspawn f()\njoin\nprintln(\"unreachable\")\n", 4},
+		{"func int f(int n)\n\tif n == 0\n\t\texit(9)\n\treturn f(n - 1)\nprintln(string(f(100)))\n", 9},
+	}
+	for _, program := range programs {
+		for _, backend := range backends {
+			code, output := runProgram("exit.selinus", program.content, runner.WithOS(), runner.WithBackend(backend))
+			if code != program.code {
+				t.Errorf("%q exited with %d, expected %d", program.content, code, program.code)
+			}
+			if output != "" {
+				t.Fatal("unexpected output ", output)
+			}
+		}
+	}
+	interpreter, err := runner.New(runner.WithOS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exit *runner.Exit
+	if err := interpreter.Eval("exit(3)\n"); !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatal("unexpected error ", err)
+	}
+}
+
+// positions matches the positions at the ends of the lines of parser.Dump.
+var positions = regexp.MustCompile(` \d+:\d+\n`)
+
+// TestFormat formats every example that parses and checks that formatting again changes nothing and that the formatted
+// program parses to the same tree and prints the same output.
+func TestFormat(t *testing.T) {
+	var programs []string
+	for _, example := range examples {
+		programs = append(programs, example.testFileContent)
+	}
+	for _, example := range compileErrors {
+		programs = append(programs, example.testFileContent)
+	}
+	for _, example := range exceptions {
+		programs = append(programs, example.testFileContent)
+	}
+	for _, program := range programs {
+		formatted, err := format.Source("format.selinus", program)
+		if err != nil {
+			if _, parseErr := runner.Parse("format.selinus", program); parseErr == nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		again, err := format.Source("format.selinus", formatted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != formatted {
+			t.Fatalf("formatting is not idempotent, formatted once:\n%s\ntwice:\n%s", formatted, again)
+		}
+		tree, err := runner.Parse("format.selinus", program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formattedTree, err := runner.Parse("format.selinus", formatted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := positions.ReplaceAllString(parser.Dump(tree), "\n"), positions.ReplaceAllString(parser.Dump(formattedTree), "\n"); expected != got {
+			t.Fatalf("formatting changed the syntax tree of\n%s\nfrom:\n%s\nto:\n%s", program, expected, got)
+		}
+	}
+	for _, example := range examples {
+		formatted, err := format.Source(example.testFilePath, example.testFileContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := &strings.Builder{}
+		if code := runner.Run(example.testFilePath, formatted, runner.WithStdout(output)); code != 0 {
+			t.Fatal("formatted ", example.testFilePath, " output code is ", code)
+		}
+		if output.String() != example.expectedOutput {
+			t.Fatalf("output mismatch, expected: %s, got: %s", example.expectedOutput, output)
+		}
+	}
+}
@@ -1,19 +1,30 @@
 package executer
 
 import (
+	"fmt"
 	"github.com/cevatbarisyilmaz/selinus/compiler/core"
 )
 
-func Execute(root core.Node, scope *core.Scope) *core.Return {
-	return execute(root, scope)
-}
-
-func execute(root core.Node, scope *core.Scope) *core.Return {
+// Execute runs the statements starting from root. A return statement, which compiler.CompileResult adds at the end of
+// a program, ends it with its value. Go panics raised while running a statement are recovered and reported as an
+// exception whose stack trace points at that statement, so a faulty program can not crash the host.
+func Execute(root core.Node, scope *core.Scope) (res *core.Return) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = core.NewExceptionReturn(fmt.Sprint("internal error: ", r))
+			if root != nil {
+				core.AddPositionToStackTrace(res, root.Position())
+			}
+		}
+	}()
 	for root != nil {
-		res := root.Execute(scope)
+		res = root.Execute(scope)
 		if res.ReturnType == core.EXCEPTION {
 			return res
 		}
+		if res.ReturnType == core.RETURN {
+			return &core.Return{ReturnType: core.NOTHING, Pointer: res.Pointer}
+		}
 		root = root.Next()
 	}
 	return &core.Return{
@@ -0,0 +1,33 @@
+package executer_test
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/executer"
+	"testing"
+)
+
+type panickingNode struct{}
+
+func (*panickingNode) Execute(*core.Scope) *core.Return {
+	var pointer *core.Pointer
+	return &core.Return{ReturnType: core.NOTHING, Pointer: pointer.Variable.ToPointer()}
+}
+
+type emptyNode struct{}
+
+func (*emptyNode) Execute(*core.Scope) *core.Return {
+	return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+}
+
+func TestExecuteRecoversPanics(t *testing.T) {
+	root := core.NewNode(&emptyNode{}, "first")
+	root.SetNext(core.NewNode(&panickingNode{}, "second"))
+	res := executer.Execute(root, core.NewScope())
+	if res.ReturnType != core.EXCEPTION {
+		t.Fatal("expected an exception, got return type ", res.ReturnType)
+	}
+	positions := res.Pointer.Variable.VariableInterface.(*core.StackTrace).Positions
+	if len(positions) != 1 || positions[0] != "second" {
+		t.Fatal("expected the stack trace to point at the panicking statement, got ", positions)
+	}
+}
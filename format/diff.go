@@ -0,0 +1,139 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines Diff prints around a change.
+const contextLines = 3
+
+// edit is a line of a diff, kind is ' ' for a line both texts have, '-' for a removed line and '+' for an added one.
+type edit struct {
+	kind byte
+	line string
+}
+
+// Diff returns the changes that turn old into formatted as a unified diff of the file at path, or an empty string when
+// they are the same.
+func Diff(path, old, formatted string) string {
+	if old == formatted {
+		return ""
+	}
+	edits := diffLines(lines(old), lines(formatted))
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "--- %s.orig\n+++ %s\n", path, path)
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(edits); {
+		if edits[start].kind == ' ' {
+			start++
+			oldLine++
+			newLine++
+			continue
+		}
+		// A hunk starts with the context before the first change and ends once a change is followed by more than twice
+		// the context lines without a change.
+		first := start - contextLines
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for unchanged := 0; end < len(edits) && unchanged <= 2*contextLines; end++ {
+			if edits[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		last := end
+		for last > start && edits[last-1].kind == ' ' {
+			last--
+		}
+		last += contextLines
+		if last > len(edits) {
+			last = len(edits)
+		}
+		oldStart, newStart := oldLine-(start-first), newLine-(start-first)
+		oldCount, newCount := 0, 0
+		for _, e := range edits[first:last] {
+			if e.kind != '+' {
+				oldCount++
+			}
+			if e.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(builder, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, e := range edits[first:last] {
+			builder.WriteByte(e.kind)
+			builder.WriteString(e.line)
+			builder.WriteString("\n")
+		}
+		for _, e := range edits[start:last] {
+			if e.kind != '+' {
+				oldLine++
+			}
+			if e.kind != '-' {
+				newLine++
+			}
+		}
+		start = last
+	}
+	return builder.String()
+}
+
+// hunkRange returns the range of a hunk header. An empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the edits that turn a into b, keeping the longest common subsequence of their lines.
+func diffLines(a, b []string) []edit {
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && common[i+1][j] >= common[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	return edits
+}
@@ -0,0 +1,304 @@
+// Package format prints Selinus programs in a canonical layout. Blocks are indented with one tab per level and closed
+// by their indentation alone, tokens are separated by single spaces where the language needs or allows them, every
+// statement gets a line of its own and runs of blank lines are reduced to one.
+//
+// The language has no comments yet, so the lexer returns no comment tokens and the printer has none to keep. Once
+// comments are added, the lexer has to return them as tokens and the printer has to attach them to the statements
+// around them, or formatting would drop them.
+package format
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/lexer"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+	"github.com/cevatbarisyilmaz/selinus/reader"
+	"strings"
+)
+
+// Source formats the source code of a program, fileName is used in the errors. Programs that do not parse are not
+// formatted.
+func Source(fileName, src string) (string, error) {
+	tokens, err := lexer.Lex(reader.ReadString(src), fileName)
+	if err != nil {
+		return "", err
+	}
+	return Tokens(tokens)
+}
+
+// Tokens formats the program made of the tokens returned by lexer.Lex.
+func Tokens(tokens []*lexer.LexicalToken) (string, error) {
+	if _, err := parser.Parse(tokens); err != nil {
+		return "", err
+	}
+	p := &printer{statements: divide(tokens)}
+	p.block(rootBlock, 0)
+	return p.builder.String(), nil
+}
+
+// statement is a line of the formatted program, or an Indent or a Dedent token on its own.
+type statement struct {
+	tokens []*lexer.LexicalToken
+	// blank is set when blank lines preceded the statement.
+	blank bool
+}
+
+func (s *statement) is(tokenType lexer.TokenType, value string) bool {
+	return s.tokens[0].TokenType == tokenType && (value == "" || s.tokens[0].Value == value)
+}
+
+func (s *statement) isKeyword(value string) bool {
+	return s.is(lexer.Keyword, value)
+}
+
+// divide splits the tokens into statements the same way the parser does: at the new lines that are not inside
+// parentheses or after a binary operator and at semicolons.
+func divide(tokens []*lexer.LexicalToken) []*statement {
+	var statements []*statement
+	current := &statement{}
+	depth := 0
+	expecting := false
+	newLines := 0
+	flush := func() {
+		if len(current.tokens) > 0 {
+			statements = append(statements, current)
+			current = &statement{}
+		}
+	}
+	for _, token := range tokens {
+		switch {
+		case token.TokenType == lexer.NewLine:
+			if depth > 0 || expecting {
+				continue
+			}
+			flush()
+			newLines++
+			current.blank = current.blank || newLines > 1
+			continue
+		case depth == 0 && token.TokenType == lexer.SemiColon:
+			flush()
+			continue
+		case depth == 0 && (token.TokenType == lexer.Indent || token.TokenType == lexer.Dedent):
+			flush()
+			blank := current.blank
+			statements = append(statements, &statement{tokens: []*lexer.LexicalToken{token}})
+			current.blank = blank
+			expecting = false
+			continue
+		case token.TokenType == lexer.LeftParenthesis:
+			depth++
+		case token.TokenType == lexer.RightParenthesis:
+			depth--
+		}
+		newLines = 0
+		expecting = token.TokenType == lexer.Operator && token.Value != lexer.Increase && token.Value != lexer.Decrease
+		current.tokens = append(current.tokens, token)
+	}
+	flush()
+	return statements
+}
+
+type blockMode int
+
+const (
+	rootBlock blockMode = iota
+	// indentedBlock is closed by a dedent, optionally preceded or followed by an end.
+	indentedBlock
+	// endedBlock is not indented, it is closed by an end, a return, an else or the dedent of the enclosing block.
+	endedBlock
+)
+
+// printer prints the statements with the indentation of the blocks the parser puts them in, see parser.formBlock.
+type printer struct {
+	statements []*statement
+	i          int
+	builder    strings.Builder
+	// empty is set while nothing was printed in the current block.
+	empty bool
+}
+
+func (p *printer) next() *statement {
+	if p.i < len(p.statements) {
+		return p.statements[p.i]
+	}
+	return nil
+}
+
+// block prints the statements of a block at the given depth and returns whether it had any.
+func (p *printer) block(mode blockMode, depth int) bool {
+	p.empty = true
+	printed := false
+	for s := p.next(); s != nil; s = p.next() {
+		switch {
+		case s.is(lexer.Dedent, ""):
+			if mode == indentedBlock {
+				p.i++
+			}
+			return printed
+		case s.isKeyword(lexer.Else) && mode == endedBlock:
+			return printed
+		case s.isKeyword(lexer.End):
+			// An end is followed by the dedent of the indented block it closes.
+			if mode == indentedBlock {
+				p.i++
+			}
+			p.i++
+			return printed
+		}
+		p.print(s, depth)
+		printed = true
+		p.i++
+		switch {
+		case s.isKeyword(lexer.Return) && mode == endedBlock:
+			return printed
+		case s.isKeyword(lexer.If):
+			p.body(depth, true)
+		case s.isKeyword(lexer.Loop), s.isKeyword(lexer.Function):
+			p.body(depth, false)
+		case s.isKeyword(lexer.Select):
+			p.cases(depth)
+		}
+	}
+	return printed
+}
+
+// body prints the block that follows the header of a function, condition or loop at the given depth and, for
+// conditions, the else branch after it, see parser.formBody.
+func (p *printer) body(depth int, condition bool) {
+	if s := p.next(); s != nil && s.is(lexer.Indent, "") {
+		p.i++
+		p.block(indentedBlock, depth+1)
+		if s := p.next(); s != nil && s.isKeyword(lexer.End) && !p.statements[p.i-2].isKeyword(lexer.End) {
+			p.i++
+		}
+	} else if !p.block(endedBlock, depth+1) {
+		// A block without statements can only be closed by an end.
+		p.line(depth, "end")
+	}
+	s := p.next()
+	if !condition || s == nil || !s.isKeyword(lexer.Else) {
+		return
+	}
+	p.print(s, depth)
+	p.i++
+	p.body(depth, len(s.tokens) > 1)
+}
+
+// cases prints the indented cases that follow a select at the given depth, see parser.formCases.
+func (p *printer) cases(depth int) {
+	p.i++
+	for s := p.next(); s != nil; s = p.next() {
+		if s.is(lexer.Dedent, "") {
+			p.i++
+			return
+		}
+		if s.isKeyword(lexer.End) {
+			p.i += 2
+			return
+		}
+		p.print(s, depth+1)
+		p.i++
+		if s := p.next(); s != nil && s.is(lexer.Indent, "") {
+			p.body(depth+1, false)
+		}
+	}
+}
+
+// print prints a statement on a line of its own, preceded by a blank line when it had one and it is not the first
+// statement of its block.
+func (p *printer) print(s *statement, depth int) {
+	if s.blank && !p.empty && !s.isKeyword(lexer.Else) {
+		p.builder.WriteString("\n")
+	}
+	p.line(depth, join(s.tokens))
+}
+
+func (p *printer) line(depth int, text string) {
+	p.builder.WriteString(strings.Repeat("\t", depth))
+	p.builder.WriteString(text)
+	p.builder.WriteString("\n")
+	p.empty = false
+}
+
+// join returns the tokens of a statement separated by spaces, leaving them out inside parentheses, before commas and
+// postfix operators, between a function and its arguments and after unary minuses.
+func join(tokens []*lexer.LexicalToken) string {
+	builder := &strings.Builder{}
+	var previous *lexer.LexicalToken
+	unary := false
+	for _, token := range tokens {
+		if previous != nil && !unary && spaced(previous, token) {
+			builder.WriteString(" ")
+		}
+		unary = token.TokenType == lexer.Operator && token.Value == lexer.Minus && operand(previous)
+		builder.WriteString(text(token))
+		previous = token
+	}
+	return builder.String()
+}
+
+// operand reports whether an operand is expected after the token, which makes a minus that follows it unary.
+func operand(token *lexer.LexicalToken) bool {
+	if token == nil {
+		return true
+	}
+	switch token.TokenType {
+	case lexer.LeftParenthesis, lexer.Coma:
+		return true
+	case lexer.Operator:
+		return token.Value != lexer.Increase && token.Value != lexer.Decrease
+	case lexer.Keyword:
+		return token.Value != lexer.True && token.Value != lexer.False
+	}
+	return false
+}
+
+func spaced(previous *lexer.LexicalToken, token *lexer.LexicalToken) bool {
+	switch {
+	case previous.TokenType == lexer.LeftParenthesis:
+		return false
+	case token.TokenType == lexer.RightParenthesis, token.TokenType == lexer.Coma:
+		return false
+	case token.TokenType == lexer.Operator && (token.Value == lexer.Increase || token.Value == lexer.Decrease):
+		return false
+	case token.TokenType == lexer.LeftParenthesis:
+		return previous.TokenType != lexer.Identifier && previous.TokenType != lexer.RightParenthesis
+	}
+	return true
+}
+
+// text returns the source code of the token.
+func text(token *lexer.LexicalToken) string {
+	switch token.TokenType {
+	case lexer.LeftParenthesis:
+		return "("
+	case lexer.RightParenthesis:
+		return ")"
+	case lexer.Coma:
+		return ","
+	case lexer.BigInteger:
+		return token.Value + string(lexer.BigIntegerSuffix)
+	case lexer.Text:
+		return quote(token.Value)
+	}
+	return token.Value
+}
+
+// quote returns the string literal whose value is text, the reverse of the escapes the lexer resolves. The lexer keeps
+// a backslash that does not start an escape, so only the backslashes that would start one are escaped.
+func quote(text string) string {
+	builder := &strings.Builder{}
+	builder.WriteString(`"`)
+	for i, r := range text {
+		switch {
+		case r == '"':
+			builder.WriteString(`\"`)
+		case r == '\n':
+			builder.WriteString(`\n`)
+		case r == '\\' && (i+1 == len(text) || strings.ContainsRune(`"n\`, rune(text[i+1]))):
+			builder.WriteString(`\\`)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	builder.WriteString(`"`)
+	return builder.String()
+}
@@ -0,0 +1,39 @@
+package format_test
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/format"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	src := "\n\nfunc int f(int a)\nreturn a*2\nfunc g()\nend\nif f(1)==2\nprintln( \"two\" ) ; println(\"x\\ty \\\\n \\\"q\\\"\")\nelse\n" +
+		"println(\"no\")\nend\nint x=1+\n  2\nloop 1 to 3 as i\n\tx = x- -1\n\n\n\tx = x + 1\n\tend\n\n\nfunc h()\n    if x == 1\n" +
+		"        println(\"a\")\n    end\n    end\nselect\n\treceive(channel(int,1)) as v\n\t\tprintln(string(v))\n\tend\n"
+	expected := "func int f(int a)\n\treturn a * 2\nfunc g()\nend\nif f(1) == 2\n\tprintln(\"two\")\n\tprintln(\"x\\ty \\\\n \\\"q\\\"\")\n" +
+		"else\n\tprintln(\"no\")\nint x = 1 + 2\nloop 1 to 3 as i\n\tx = x - -1\n\n\tx = x + 1\n\nfunc h()\n\tif x == 1\n\t\tprintln(\"a\")\n" +
+		"select\n\treceive(channel(int, 1)) as v\n\t\tprintln(string(v))\n"
+	formatted, err := format.Source("source.selinus", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, formatted)
+	}
+	if _, err := format.Source("source.selinus", "int x = (\n"); err == nil {
+		t.Fatal("a program that does not parse was formatted")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	formatted := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nm\nn\n"
+	expected := "--- diff.selinus.orig\n+++ diff.selinus\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -9,5 +9,5 @@\n i\n j\n k\n-l\n m\n+n\n"
+	if diff := format.Diff("diff.selinus", old, formatted); diff != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, diff)
+	}
+	if diff := format.Diff("diff.selinus", old, old); diff != "" {
+		t.Fatal("unexpected diff ", diff)
+	}
+}
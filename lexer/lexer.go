@@ -51,8 +51,34 @@ const (
 	SemiColon
 	Text
 	Integer
+	BigInteger
+	Indent
+	Dedent
 )
 
+var tokenTypeNames = [...]string{
+	Keyword:          "Keyword",
+	Operator:         "Operator",
+	Identifier:       "Identifier",
+	LeftParenthesis:  "LeftParenthesis",
+	RightParenthesis: "RightParenthesis",
+	NewLine:          "NewLine",
+	Coma:             "Coma",
+	SemiColon:        "SemiColon",
+	Text:             "Text",
+	Integer:          "Integer",
+	BigInteger:       "BigInteger",
+	Indent:           "Indent",
+	Dedent:           "Dedent",
+}
+
+func (typ TokenType) String() string {
+	if int(typ) < len(tokenTypeNames) {
+		return tokenTypeNames[typ]
+	}
+	return "TokenType(" + strconv.Itoa(int(typ)) + ")"
+}
+
 const (
 	Function = "func"
 	Return   = "return"
@@ -64,6 +90,11 @@ const (
 	Loop     = "loop"
 	As       = "as"
 	To       = "to"
+	Const    = "const"
+	Let      = "let"
+	Spawn    = "spawn"
+	Join     = "join"
+	Select   = "select"
 )
 
 const (
@@ -82,10 +113,13 @@ const (
 	Increase       = "++"
 	Decrease       = "--"
 	Or             = "||"
+	And            = "&&"
 )
 
+const BigIntegerSuffix = 'n'
+
 func isKeyword(word string) bool {
-	keywords := [...]string{Function, Return, End, If, Else, True, False, Loop, As, To}
+	keywords := [...]string{Function, Return, End, If, Else, True, False, Loop, As, To, Const, Let, Spawn, Join, Select}
 	for _, r := range keywords {
 		if r == word {
 			return true
@@ -94,8 +128,9 @@ func isKeyword(word string) bool {
 	return false
 }
 
+// isOperator reports whether op is an operator the parser knows. Not is reserved, a ! is only accepted as part of !=.
 func isOperator(op string) bool {
-	operators := [...]string{Gets, Plus, Minus, Multiply, Divide, Equal, NotEqual, Greater, GreaterOrEqual, Less, LessOrEqual, Not, Increase, Decrease, Or}
+	operators := [...]string{Gets, Plus, Minus, Multiply, Divide, Equal, NotEqual, Greater, GreaterOrEqual, Less, LessOrEqual, Increase, Decrease, Or, And}
 	for _, r := range operators {
 		if r == op {
 			return true
@@ -105,6 +140,10 @@ func isOperator(op string) bool {
 }
 
 type state struct {
+	atLineStart   bool
+	indentation   strings.Builder
+	indents       []string
+	depth         int
 	line          int
 	oldPosition   int
 	position      int
@@ -122,8 +161,25 @@ func Lex(br *bufio.Reader, fileName string) ([]*LexicalToken, error) {
 	s := &state{file: fileName}
 	s.line = 1
 	s.reader = br
+	s.atLineStart = true
+	s.indents = []string{""}
 	for s.err == nil {
 		s.next()
+		if s.atLineStart {
+			if s.r == ' ' || s.r == '\t' {
+				s.indentation.WriteRune(s.r)
+				continue
+			}
+			if s.r == '\n' || s.r == '\r' || s.err != nil {
+				s.indentation.Reset()
+			} else {
+				s.indent()
+				s.atLineStart = false
+				if s.err != nil {
+					break
+				}
+			}
+		}
 		if unicode.IsLetter(s.r) {
 			s.buffer.WriteRune(s.r)
 			s.lexIdentifierOrKeyword()
@@ -136,10 +192,12 @@ func Lex(br *bufio.Reader, fileName string) ([]*LexicalToken, error) {
 			t := s.tokenTemplate()
 			t.TokenType = LeftParenthesis
 			s.tokens = append(s.tokens, t)
+			s.depth++
 		} else if s.r == ')' {
 			t := s.tokenTemplate()
 			t.TokenType = RightParenthesis
 			s.tokens = append(s.tokens, t)
+			s.depth--
 		} else if s.r == ',' {
 			t := s.tokenTemplate()
 			t.TokenType = Coma
@@ -149,10 +207,12 @@ func Lex(br *bufio.Reader, fileName string) ([]*LexicalToken, error) {
 			t.TokenType = SemiColon
 			s.tokens = append(s.tokens, t)
 		} else if s.r == '\n' {
+			s.atLineStart = s.depth <= 0 && !s.continuesLine()
+			s.indentation.Reset()
 			t := s.tokenTemplate()
 			t.TokenType = NewLine
 			s.tokens = append(s.tokens, t)
-		} else if strings.Contains("+-*/=|&", string(s.r)) {
+		} else if strings.Contains("+-*/=|&<>!", string(s.r)) {
 			s.buffer.WriteRune(s.r)
 			s.lexOperator()
 		} else if unicode.IsSpace(s.r) || s.r == 0 {
@@ -162,11 +222,61 @@ func Lex(br *bufio.Reader, fileName string) ([]*LexicalToken, error) {
 		}
 	}
 	if s.err == io.EOF {
+		for len(s.indents) > 1 {
+			s.indents = s.indents[:len(s.indents)-1]
+			t := s.tokenTemplate()
+			t.TokenType = Dedent
+			s.tokens = append(s.tokens, t)
+		}
 		return s.tokens, nil
 	}
 	return nil, s.err
 }
 
+// indent compares the indentation of the line that is about to be lexed with the enclosing ones and emits the
+// Indent or Dedent tokens that lead to it.
+func (s *state) indent() {
+	current := s.indentation.String()
+	s.indentation.Reset()
+	top := s.indents[len(s.indents)-1]
+	if current == top {
+		return
+	}
+	if strings.HasPrefix(current, top) {
+		s.indents = append(s.indents, current)
+		t := s.tokenTemplate()
+		t.TokenType = Indent
+		s.tokens = append(s.tokens, t)
+		return
+	}
+	if !strings.HasPrefix(top, current) {
+		s.err = errors.New("inconsistent use of tabs and spaces in indentation" + s.tokenTemplate().ToString())
+		return
+	}
+	for len(s.indents) > 1 && len(s.indents[len(s.indents)-1]) > len(current) {
+		s.indents = s.indents[:len(s.indents)-1]
+		t := s.tokenTemplate()
+		t.TokenType = Dedent
+		s.tokens = append(s.tokens, t)
+	}
+	if s.indents[len(s.indents)-1] != current {
+		s.err = errors.New("indentation does not match any outer indentation level" + s.tokenTemplate().ToString())
+	}
+}
+
+// continuesLine reports whether the statement on the current line continues on the next one, which happens when the
+// line ends with a binary operator.
+func (s *state) continuesLine() bool {
+	for i := len(s.tokens) - 1; i >= 0; i-- {
+		t := s.tokens[i]
+		if t.TokenType == NewLine {
+			continue
+		}
+		return t.TokenType == Operator && t.Value != Increase && t.Value != Decrease
+	}
+	return false
+}
+
 func (s *state) advance() {
 	s.r, _, s.err = s.reader.ReadRune()
 	s.oldPosition = s.position
@@ -233,6 +343,9 @@ func (s *state) lexInteger() {
 		}
 		if unicode.IsDigit(s.r) {
 			s.buffer.WriteRune(s.r)
+		} else if s.r == BigIntegerSuffix {
+			s.lexBigIntegerEnd()
+			return
 		} else {
 			s.lexIntegerEnd()
 			s.fallBack()
@@ -247,6 +360,21 @@ func (s *state) lexIntegerEnd() {
 	s.tokens = append(s.tokens, t)
 }
 
+func (s *state) lexBigIntegerEnd() {
+	t := s.tokenTemplate()
+	t.TokenType = BigInteger
+	s.tokens = append(s.tokens, t)
+	s.advance()
+	if s.err != nil {
+		return
+	}
+	if unicode.IsLetter(s.r) || unicode.IsDigit(s.r) || s.r == '_' {
+		s.err = errors.New("unexpected character " + string(s.r) + " after big integer literal " + t.ToString())
+		return
+	}
+	s.fallBack()
+}
+
 func (s *state) lexString() {
 	escape := false
 	for {
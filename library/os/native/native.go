@@ -0,0 +1,143 @@
+package native
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"os"
+	"strconv"
+)
+
+var ArgsFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "args", Methods: nil, Generic: true, Generics: []*core.Type{builtin.IntegerType}}
+
+var ArgFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "arg", Methods: nil, Generic: true, Generics: []*core.Type{builtin.StringType, builtin.IntegerType}}
+
+var ExitFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "exit", Methods: nil, Generic: true, Generics: []*core.Type{nil, builtin.IntegerType}}
+
+// maxExitCode is the largest exit status a process can report.
+const maxExitCode = 255
+
+// ArgsFunction returns the number of arguments the program was started with.
+type ArgsFunction struct{}
+
+func (*ArgsFunction) Execute(scope *core.Scope) *core.Return {
+	return &core.Return{Pointer: builtin.NewIntegerPointer(int64(len(args(scope)))), ReturnType: core.NOTHING}
+}
+
+// args returns the arguments of the program that runs in the scope.
+func args(scope *core.Scope) []string {
+	if scope.Thread == nil {
+		return nil
+	}
+	return scope.Thread.Args
+}
+
+func (*ArgsFunction) GetType() *core.Type {
+	return ArgsFunctionType
+}
+
+func (*ArgsFunction) GetParameters() []*core.Parameter {
+	return []*core.Parameter{}
+}
+
+func (*ArgsFunction) GetReturnType() *core.Type {
+	return builtin.IntegerType
+}
+
+func (*ArgsFunction) GetScope() *core.Scope {
+	return scope
+}
+
+// ArgFunction returns the argument of the program at the given index, which starts from 1.
+type ArgFunction struct{}
+
+func (*ArgFunction) Execute(scope *core.Scope) *core.Return {
+	index, exception := integer(scope, "arg", "index")
+	if exception != nil {
+		return exception
+	}
+	programArgs := args(scope)
+	if index < 1 || index > int64(len(programArgs)) {
+		return core.NewExceptionReturn("arg: index " + strconv.FormatInt(index, 10) + " is out of range, the program has " + strconv.Itoa(len(programArgs)) + " arguments")
+	}
+	return &core.Return{Pointer: builtin.NewStringPointer(programArgs[index-1]), ReturnType: core.NOTHING}
+}
+
+// integer returns the value of the integer parameter of the function named name.
+func integer(scope *core.Scope, name string, parameter string) (int64, *core.Return) {
+	scopeResult := scope.Get(parameter)
+	if scopeResult.ReturnType != core.NOTHING {
+		return 0, scopeResult
+	}
+	if scopeResult.Pointer.Variable == nil {
+		return 0, core.NewExceptionReturn(name + ": " + parameter + " is uninitialized")
+	}
+	value, ok := scopeResult.Pointer.Variable.VariableInterface.(*builtin.Integer)
+	if !ok {
+		return 0, core.NewExceptionReturn(name + ": expected Integer but got " + scopeResult.Pointer.Variable.GetType().Name)
+	}
+	return value.Value, nil
+}
+
+func (*ArgFunction) GetType() *core.Type {
+	return ArgFunctionType
+}
+
+func (*ArgFunction) GetParameters() []*core.Parameter {
+	return []*core.Parameter{{Name: "index", Typ: builtin.IntegerType}}
+}
+
+func (*ArgFunction) GetReturnType() *core.Type {
+	return builtin.StringType
+}
+
+func (*ArgFunction) GetScope() *core.Scope {
+	return scope
+}
+
+// ExitFunction ends the program with the given exit status.
+type ExitFunction struct{}
+
+func (*ExitFunction) Execute(scope *core.Scope) *core.Return {
+	code, exception := integer(scope, "exit", "code")
+	if exception != nil {
+		return exception
+	}
+	if code < 0 || code > maxExitCode {
+		return core.NewExceptionReturn("exit: code " + strconv.FormatInt(code, 10) + " is not between 0 and " + strconv.Itoa(maxExitCode))
+	}
+	return core.NewExitReturn(int(code))
+}
+
+func (*ExitFunction) GetType() *core.Type {
+	return ExitFunctionType
+}
+
+func (*ExitFunction) GetParameters() []*core.Parameter {
+	return []*core.Parameter{{Name: "code", Typ: builtin.IntegerType}}
+}
+
+func (*ExitFunction) GetReturnType() *core.Type {
+	return nil
+}
+
+func (*ExitFunction) GetScope() *core.Scope {
+	return scope
+}
+
+var argsFunction core.VariableInterface = &ArgsFunction{}
+var argFunction core.VariableInterface = &ArgFunction{}
+var exitFunction core.VariableInterface = &ExitFunction{}
+
+var scope = core.NewScopeWithName("native")
+
+func init() {
+	scope.AddBlock(Block)
+}
+
+var Block = core.NewScopeBlock(map[string]*core.Pointer{
+	"args": {Typ: ArgsFunctionType, Variable: core.NewVariable(argsFunction), Immutable: true},
+	"arg":  {Typ: ArgFunctionType, Variable: core.NewVariable(argFunction), Immutable: true},
+	"exit": {Typ: ExitFunctionType, Variable: core.NewVariable(exitFunction), Immutable: true},
+	"env":  builtin.MustNewFunctionPointer("env", os.Getenv),
+	"cwd":  builtin.MustNewFunctionPointer("cwd", os.Getwd),
+})
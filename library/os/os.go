@@ -0,0 +1,14 @@
+// Package os is the module that gives programs access to the process they run in: their arguments, the environment,
+// the working directory and the exit status.
+package os
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/library/os/native"
+	"github.com/cevatbarisyilmaz/selinus/module"
+)
+
+// Module declares args, arg, env, cwd and exit. It has no root file, all of its functions are natives.
+var Module = &module.Module{
+	NativeBlock: native.Block,
+	Name:        "os.selinus",
+}
@@ -0,0 +1,22 @@
+//go:build ignore
+
+// gen compiles the root file of the standard library to self/standard.selc.
+package main
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/library/standard"
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"os"
+)
+
+func main() {
+	data, err := runner.BuildModule(standard.Module)
+	if err == nil {
+		err = os.WriteFile("self/standard.selc", data, 0644)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -5,33 +5,41 @@ import (
 	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
 	"github.com/cevatbarisyilmaz/selinus/compiler/core"
 	"io"
-	"os"
 )
 
 var PrintFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "print", Methods: nil, Generic: true, Generics: []*core.Type{nil, builtin.StringType}}
 
 var ScanIntegerFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "scanInteger", Methods: nil, Generic: true, Generics: []*core.Type{builtin.IntegerType}}
 
-var defaultOutputWriter io.Writer = os.Stdout
-
-func SetDefaultOutputWriter(writer io.Writer) {
-	defaultOutputWriter = writer
-}
+var EprintFunctionType = &core.Type{Parent: builtin.FunctionType, Name: "eprint", Methods: nil, Generic: true, Generics: []*core.Type{nil, builtin.StringType}}
 
+// PrintFunction writes the text to the output of the program.
 type PrintFunction struct{}
 
 func (*PrintFunction) Execute(scope *core.Scope) *core.Return {
+	return write(scope, "print", scope.Thread.Output())
+}
+
+// write writes the text parameter of the function named name to the writer.
+func write(scope *core.Scope, name string, writer io.Writer) *core.Return {
 	scopeResult := scope.Get("text")
 	if scopeResult.ReturnType != core.NOTHING {
 		return scopeResult
 	}
+	if scopeResult.Pointer.Variable == nil {
+		return core.NewExceptionReturn(name + ": text is uninitialized")
+	}
 	r := scopeResult.Pointer.Variable.ConvertTo(builtin.StringType)
 	if r.ReturnType != core.NOTHING {
 		return r
 	}
-	_, err := fmt.Fprint(defaultOutputWriter, r.Pointer.Variable.VariableInterface.(*builtin.String).Value)
+	text, ok := r.Pointer.Variable.VariableInterface.(*builtin.String)
+	if !ok {
+		return core.NewExceptionReturn(name + ": expected String but got " + r.Pointer.Variable.GetType().Name)
+	}
+	_, err := fmt.Fprint(writer, text.Value)
 	if err != nil {
-		return core.NewExceptionReturn("print failed: " + err.Error())
+		return core.NewExceptionReturn(name + " failed: " + err.Error())
 	}
 	return &core.Return{Pointer: nil, ReturnType: core.NOTHING}
 }
@@ -52,11 +60,40 @@ func (*PrintFunction) GetScope() *core.Scope {
 	return scope
 }
 
+// EprintFunction writes the text to the error output of the program.
+type EprintFunction struct{}
+
+func (*EprintFunction) Execute(scope *core.Scope) *core.Return {
+	return write(scope, "eprint", scope.Thread.ErrorOutput())
+}
+
+func (*EprintFunction) GetType() *core.Type {
+	return EprintFunctionType
+}
+
+func (*EprintFunction) GetParameters() []*core.Parameter {
+	return []*core.Parameter{{Name: "text", Typ: builtin.StringType, DefaultValue: builtin.NewStringPointer("")}}
+}
+
+func (*EprintFunction) GetReturnType() *core.Type {
+	return nil
+}
+
+func (*EprintFunction) GetScope() *core.Scope {
+	return scope
+}
+
+// ScanIntegerFunction reads an integer from the input of the program.
 type ScanIntegerFunction struct{}
 
 func (*ScanIntegerFunction) Execute(scope *core.Scope) *core.Return {
 	var i int64
-	_, err := fmt.Scanf("%d", &i)
+	var err error
+	if exception := scope.Thread.Read(func() {
+		_, err = fmt.Fscan(scope.Thread.Input(), &i)
+	}); exception != nil {
+		return exception
+	}
 	if err != nil {
 		return core.NewExceptionReturn(fmt.Sprintf("scanInteger: %v", err))
 	}
@@ -80,6 +117,7 @@ func (*ScanIntegerFunction) GetScope() *core.Scope {
 }
 
 var printFunction core.VariableInterface = &PrintFunction{}
+var eprintFunction core.VariableInterface = &EprintFunction{}
 var scanIntegerFunction core.VariableInterface = &ScanIntegerFunction{}
 
 var scope = core.NewScopeWithName("native")
@@ -89,6 +127,7 @@ func init() {
 }
 
 var Block = core.NewScopeBlock(map[string]*core.Pointer{
-	"print":       {Typ: PrintFunctionType, Variable: core.NewVariable(printFunction)},
-	"scanInteger": {Typ: ScanIntegerFunctionType, Variable: core.NewVariable(scanIntegerFunction)},
+	"print":       {Typ: PrintFunctionType, Variable: core.NewVariable(printFunction), Immutable: true},
+	"eprint":      {Typ: EprintFunctionType, Variable: core.NewVariable(eprintFunction), Immutable: true},
+	"scanInteger": {Typ: ScanIntegerFunctionType, Variable: core.NewVariable(scanIntegerFunction), Immutable: true},
 })
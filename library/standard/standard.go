@@ -6,11 +6,17 @@ import (
 	"github.com/cevatbarisyilmaz/selinus/module"
 )
 
+//go:generate go run gen.go
+
 //go:embed self/standard.selinus
 var rootFile string
 
+//go:embed self/standard.selc
+var compiledFile []byte
+
 var Module = &module.Module{
 	NativeBlock: native.Block,
 	RootFile:    rootFile,
 	Name:        "standard.selinus",
+	Compiled:    compiledFile,
 }
@@ -0,0 +1,434 @@
+// Package lint finds suspicious code in programs that compile: variables and parameters that are never read, code
+// after a return, declarations that shadow others, functions that call themselves on every path and comparisons whose
+// result is known before the program runs.
+package lint
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/lexer"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Severities of diagnostics. The passes of Check report warnings, errors are the ones that stop a program from
+// compiling.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules of the warnings reported by Check.
+const (
+	RuleUnused             = "unused"
+	RuleUnreachable        = "unreachable"
+	RuleShadow             = "shadow"
+	RuleNeverReturns       = "never-returns"
+	RuleConstantComparison = "constant-comparison"
+)
+
+// Diagnostic is a problem found in a program at the given position.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Position int    `json:"position"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.File, d.Line, d.Position, d.Severity, d.Message, d.Rule)
+}
+
+// Check runs every pass on the statements starting from root, which must compile, and returns the warnings ordered by
+// their positions.
+func Check(root *parser.ParseNode) []*Diagnostic {
+	l := &linter{}
+	l.block(root)
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Position < b.Position
+	})
+	return l.diagnostics
+}
+
+// declaration is a name declared by a block.
+type declaration struct {
+	token *lexer.LexicalToken
+	kind  declarationKind
+	used  bool
+}
+
+type declarationKind int
+
+const (
+	variable declarationKind = iota
+	parameter
+	// loopVariable is the variable of a loop, which is declared by every loop whether it is used or not.
+	loopVariable
+	function
+)
+
+type scope map[string]*declaration
+
+type linter struct {
+	scopes      []scope
+	diagnostics []*Diagnostic
+}
+
+func (l *linter) report(token *lexer.LexicalToken, rule string, message string) {
+	l.diagnostics = append(l.diagnostics, &Diagnostic{
+		File:     token.File,
+		Line:     token.Line,
+		Position: token.Position,
+		Severity: SeverityWarning,
+		Rule:     rule,
+		Message:  message,
+	})
+}
+
+func (l *linter) push() {
+	l.scopes = append(l.scopes, scope{})
+}
+
+// pop ends the innermost scope and reports its variables and parameters that were never read.
+func (l *linter) pop() {
+	for _, d := range l.scopes[len(l.scopes)-1] {
+		switch {
+		case d.used:
+		case d.kind == variable:
+			l.report(d.token, RuleUnused, d.token.Value+" is declared but never used")
+		case d.kind == parameter:
+			l.report(d.token, RuleUnused, "parameter "+d.token.Value+" is never used")
+		}
+	}
+	l.scopes = l.scopes[:len(l.scopes)-1]
+}
+
+// declare declares the name of the token in the innermost scope and reports the declaration it hides.
+func (l *linter) declare(token *lexer.LexicalToken, kind declarationKind) {
+	name := token.Value
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if previous, ok := l.scopes[i][name]; ok {
+			verb := "shadows"
+			if i == len(l.scopes)-1 {
+				verb = "redeclares"
+			}
+			l.report(token, RuleShadow, fmt.Sprintf("%s %s the declaration at line %d position %d", name, verb, previous.token.Line, previous.token.Position))
+			break
+		}
+	}
+	l.scopes[len(l.scopes)-1][name] = &declaration{token: token, kind: kind}
+}
+
+// use marks the innermost declaration of the name as read.
+func (l *linter) use(name string) {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if d, ok := l.scopes[i][name]; ok {
+			d.used = true
+			return
+		}
+	}
+}
+
+// block checks the statements starting from root in a scope of their own and reports the first one that follows a
+// statement that always returns.
+func (l *linter) block(root *parser.ParseNode) {
+	l.push()
+	reported := false
+	for statement, returned := root, false; statement != nil; statement = statement.Next() {
+		if returned && !reported {
+			l.report(statement.GetMainToken(), RuleUnreachable, "unreachable code")
+			reported = true
+		}
+		l.statement(statement)
+		returned = returned || returns(statement)
+	}
+	l.pop()
+}
+
+func (l *linter) statement(node *parser.ParseNode) {
+	children := node.GetParseNodesWithKey(parser.Children)
+	switch node.GetType() {
+	case parser.Declaration:
+		l.declare(node.GetTokenWithKey(parser.Identifier), variable)
+	case parser.Gets:
+		l.expression(children[1])
+		l.assign(children[0])
+	case parser.Constant, parser.Let:
+		l.expression(children[1])
+		l.declare(children[0].GetMainToken(), variable)
+	case parser.Function:
+		name := node.GetTokenWithKey(parser.Identifier)
+		l.declare(name, function)
+		l.push()
+		for _, p := range node.GetParseNodesWithKey(parser.Parameters) {
+			l.declare(p.GetTokenWithKey(parser.Identifier), parameter)
+		}
+		l.block(children[0])
+		l.pop()
+		if recurses(children[0], name.Value) {
+			l.report(name, RuleNeverReturns, name.Value+" never returns, every path through it calls "+name.Value+" again")
+		}
+	case parser.If:
+		l.expression(children[0])
+		l.block(children[1])
+		for _, otherwise := range node.GetParseNodesWithKey(parser.Otherwise) {
+			l.block(otherwise)
+		}
+	case parser.ToLoop:
+		l.expression(node.GetParseNodesWithKey(parser.From)[0])
+		l.expression(node.GetParseNodesWithKey(parser.To)[0])
+		l.push()
+		l.declare(node.GetTokenWithKey(parser.Identifier), loopVariable)
+		l.block(children[0])
+		l.pop()
+	case parser.Select:
+		for _, c := range children {
+			l.expressions(c.GetParseNodesWithKey(parser.Parameters))
+			l.push()
+			if identifier := c.GetTokenWithKey(parser.Identifier); identifier != nil {
+				l.declare(identifier, variable)
+			}
+			l.block(c.GetParseNodesWithKey(parser.Children)[0])
+			l.pop()
+		}
+		for _, otherwise := range node.GetParseNodesWithKey(parser.Otherwise) {
+			l.block(otherwise)
+		}
+	default:
+		l.expression(node)
+	}
+}
+
+// assign checks the left side of an assignment, which declares variables or assigns them without reading them.
+func (l *linter) assign(node *parser.ParseNode) {
+	switch node.GetType() {
+	case parser.Declaration:
+		l.declare(node.GetTokenWithKey(parser.Identifier), variable)
+	case parser.Csv:
+		for _, child := range node.GetParseNodesWithKey(parser.Children) {
+			l.assign(child)
+		}
+	case parser.Variable:
+	default:
+		l.expression(node)
+	}
+}
+
+func (l *linter) expressions(nodes []*parser.ParseNode) {
+	for _, node := range nodes {
+		l.expression(node)
+	}
+}
+
+func (l *linter) expression(node *parser.ParseNode) {
+	if node == nil {
+		return
+	}
+	switch node.GetType() {
+	case parser.Variable, parser.FunctionCall:
+		l.use(node.GetMainToken().Value)
+	case parser.Equal, parser.NotEqual, parser.Greater, parser.Less, parser.GreaterOrEqual, parser.LessOrEqual:
+		if result, ok := compare(node); ok {
+			l.report(node.GetMainToken(), RuleConstantComparison, fmt.Sprintf("comparison is always %t", result))
+		}
+	}
+	l.expressions(node.GetParseNodesWithKey(parser.Parameters))
+	l.expressions(node.GetParseNodesWithKey(parser.Children))
+}
+
+// returns reports whether the statement returns on every path.
+func returns(node *parser.ParseNode) bool {
+	switch node.GetType() {
+	case parser.Return:
+		return true
+	case parser.If:
+		otherwise := node.GetParseNodesWithKey(parser.Otherwise)
+		return len(otherwise) > 0 && blockReturns(node.GetParseNodesWithKey(parser.Children)[1]) && blockReturns(otherwise[0])
+	case parser.Select:
+		otherwise := node.GetParseNodesWithKey(parser.Otherwise)
+		if len(otherwise) == 0 || !blockReturns(otherwise[0]) {
+			return false
+		}
+		for _, c := range node.GetParseNodesWithKey(parser.Children) {
+			if !blockReturns(c.GetParseNodesWithKey(parser.Children)[0]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func blockReturns(root *parser.ParseNode) bool {
+	for statement := root; statement != nil; statement = statement.Next() {
+		if returns(statement) {
+			return true
+		}
+	}
+	return false
+}
+
+// recurses reports whether every path through the block calls the function with the given name before it returns or
+// reaches the end of the block.
+func recurses(root *parser.ParseNode, name string) bool {
+	for statement := root; statement != nil; statement = statement.Next() {
+		if statementRecurses(statement, name) {
+			return true
+		}
+		if !returnsAfterCall(statement, name) {
+			return false
+		}
+	}
+	return false
+}
+
+// statementRecurses reports whether every path through the statement calls the function with the given name.
+func statementRecurses(node *parser.ParseNode, name string) bool {
+	children := node.GetParseNodesWithKey(parser.Children)
+	switch node.GetType() {
+	case parser.Function, parser.Select:
+		return false
+	case parser.If:
+		if calls(children[0], name) {
+			return true
+		}
+		otherwise := node.GetParseNodesWithKey(parser.Otherwise)
+		return len(otherwise) > 0 && recurses(children[1], name) && recurses(otherwise[0], name)
+	case parser.ToLoop:
+		return calls(node.GetParseNodesWithKey(parser.From)[0], name) || calls(node.GetParseNodesWithKey(parser.To)[0], name)
+	case parser.Spawn:
+		// The spawned call runs in a task of its own, only its arguments are evaluated by the function.
+		for _, argument := range children[0].GetParseNodesWithKey(parser.Parameters) {
+			if calls(argument, name) {
+				return true
+			}
+		}
+		return false
+	}
+	return calls(node, name)
+}
+
+// returnsAfterCall reports whether every return in the statement is preceded by a call to the function with the given
+// name on its path.
+func returnsAfterCall(node *parser.ParseNode, name string) bool {
+	children := node.GetParseNodesWithKey(parser.Children)
+	switch node.GetType() {
+	case parser.Return:
+		return statementRecurses(node, name)
+	case parser.If:
+		if calls(children[0], name) {
+			return true
+		}
+		for _, otherwise := range node.GetParseNodesWithKey(parser.Otherwise) {
+			if !blockReturnsAfterCall(otherwise, name) {
+				return false
+			}
+		}
+		return blockReturnsAfterCall(children[1], name)
+	case parser.ToLoop:
+		return statementRecurses(node, name) || blockReturnsAfterCall(children[0], name)
+	case parser.Select:
+		for _, c := range children {
+			if !blockReturnsAfterCall(c.GetParseNodesWithKey(parser.Children)[0], name) {
+				return false
+			}
+		}
+		for _, otherwise := range node.GetParseNodesWithKey(parser.Otherwise) {
+			if !blockReturnsAfterCall(otherwise, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func blockReturnsAfterCall(root *parser.ParseNode, name string) bool {
+	for statement := root; statement != nil; statement = statement.Next() {
+		if statementRecurses(statement, name) {
+			return true
+		}
+		if !returnsAfterCall(statement, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// calls reports whether evaluating the expression always calls the function with the given name. The right operands
+// of && and || are evaluated only for some values of the left ones.
+func calls(node *parser.ParseNode, name string) bool {
+	if node == nil {
+		return false
+	}
+	if node.GetType() == parser.FunctionCall && node.GetMainToken().Value == name {
+		return true
+	}
+	children := node.GetParseNodesWithKey(parser.Children)
+	if node.GetType() == parser.And || node.GetType() == parser.Or {
+		return calls(children[0], name)
+	}
+	for _, child := range node.GetParseNodesWithKey(parser.Parameters) {
+		if calls(child, name) {
+			return true
+		}
+	}
+	for _, child := range children {
+		if calls(child, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare returns the result of a comparison whose operands are literals or the same variable, and false as its second
+// result for the other comparisons.
+func compare(node *parser.ParseNode) (bool, bool) {
+	children := node.GetParseNodesWithKey(parser.Children)
+	left, right := children[0], children[1]
+	var order int
+	switch {
+	case left.GetType() == parser.Variable && right.GetType() == parser.Variable && left.GetMainToken().Value == right.GetMainToken().Value:
+		order = 0
+	case isInteger(left) && isInteger(right):
+		a, _ := new(big.Int).SetString(left.GetMainToken().Value, 10)
+		b, _ := new(big.Int).SetString(right.GetMainToken().Value, 10)
+		order = a.Cmp(b)
+	case left.GetType() == parser.String && right.GetType() == parser.String:
+		order = strings.Compare(left.GetMainToken().Value, right.GetMainToken().Value)
+	case left.GetType() == parser.Boolean && right.GetType() == parser.Boolean:
+		equal := left.GetMainToken().Value == right.GetMainToken().Value
+		switch node.GetType() {
+		case parser.Equal:
+			return equal, true
+		case parser.NotEqual:
+			return !equal, true
+		}
+		return false, false
+	default:
+		return false, false
+	}
+	switch node.GetType() {
+	case parser.Equal:
+		return order == 0, true
+	case parser.NotEqual:
+		return order != 0, true
+	case parser.Greater:
+		return order > 0, true
+	case parser.Less:
+		return order < 0, true
+	case parser.GreaterOrEqual:
+		return order >= 0, true
+	}
+	return order <= 0, true
+}
+
+func isInteger(node *parser.ParseNode) bool {
+	return node.GetType() == parser.Integer || node.GetType() == parser.BigInteger
+}
@@ -0,0 +1,66 @@
+package lint_test
+
+import (
+	"encoding/json"
+	"github.com/cevatbarisyilmaz/selinus/lint"
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	src := "int x = 1\nint y\ny = 2\nfunc int f(int a, int b)\n\tint x = a\n\treturn x\nfunc int q(int a)\n\tif a > 0\n\t\treturn a\n" +
+		"\t\tprintln(\"after\")\n\treturn 0\nfunc int g(int n)\n\tif n > 0\n\t\treturn g(n - 1)\n\treturn g(n + 1)\n" +
+		"func int h(int n)\n\tif n == 0\n\t\treturn 0\n\treturn h(n - 1)\nif x == x\n\tprintln(\"same\")\nif \"a\" != \"a\"\n" +
+		"\tprintln(\"never\")\nloop 1 to 3 as i\n\tint i = 2\n\tprintln(string(i))\nprintln(string(f(1, 2) + h(3) + q(1)))\n"
+	expected := "lint.selinus:2:5: warning: y is declared but never used (unused)\n" +
+		"lint.selinus:4:23: warning: parameter b is never used (unused)\n" +
+		"lint.selinus:5:6: warning: x shadows the declaration at line 1 position 5 (shadow)\n" +
+		"lint.selinus:10:3: warning: unreachable code (unreachable)\n" +
+		"lint.selinus:12:10: warning: g never returns, every path through it calls g again (never-returns)\n" +
+		"lint.selinus:20:6: warning: comparison is always true (constant-comparison)\n" +
+		"lint.selinus:22:8: warning: comparison is always false (constant-comparison)\n" +
+		"lint.selinus:25:6: warning: i shadows the declaration at line 24 position 16 (shadow)\n"
+	diagnostics, err := runner.Lint("lint.selinus", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := &strings.Builder{}
+	for _, diagnostic := range diagnostics {
+		builder.WriteString(diagnostic.String() + "\n")
+	}
+	if builder.String() != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, builder.String())
+	}
+}
+
+func TestCheckJSON(t *testing.T) {
+	builder := &strings.Builder{}
+	if code := runner.Check("lint.selinus", "int x = 1\n", builder, true); code != runner.ExitException {
+		t.Fatalf("exited with %d, expected %d", code, runner.ExitException)
+	}
+	var diagnostics []lint.Diagnostic
+	if err := json.Unmarshal([]byte(builder.String()), &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	expected := lint.Diagnostic{File: "lint.selinus", Line: 1, Position: 5, Severity: lint.SeverityWarning, Rule: lint.RuleUnused,
+		Message: "x is declared but never used"}
+	if len(diagnostics) != 1 || diagnostics[0] != expected {
+		t.Fatalf("unexpected diagnostics %+v", diagnostics)
+	}
+	builder.Reset()
+	if code := runner.Check("lint.selinus", "int x = \"a\"\n", builder, true); code != runner.ExitCompileError {
+		t.Fatalf("exited with %d, expected %d", code, runner.ExitCompileError)
+	}
+	diagnostics = nil
+	if err := json.Unmarshal([]byte(builder.String()), &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Severity != lint.SeverityError || diagnostics[0].Line != 1 {
+		t.Fatalf("unexpected diagnostics %+v", diagnostics)
+	}
+	builder.Reset()
+	if code := runner.Check("lint.selinus", "println(\"ok\")\n", builder, true); code != runner.ExitSuccess || builder.String() != "[]\n" {
+		t.Fatalf("exited with %d and printed %q", code, builder.String())
+	}
+}
@@ -6,4 +6,6 @@ type Module struct {
 	NativeBlock *core.ScopeBlock
 	RootFile    string
 	Name        string
+	// Compiled is the root file compiled to bytecode, it spares the virtual machine compiling the module on every run.
+	Compiled []byte
 }
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/lexer"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var parseNodeTypeNames = [...]string{
+	String:         "String",
+	Integer:        "Integer",
+	Boolean:        "Boolean",
+	Function:       "Function",
+	Summation:      "Summation",
+	Subtraction:    "Subtraction",
+	Divide:         "Divide",
+	Equal:          "Equal",
+	Greater:        "Greater",
+	Less:           "Less",
+	Or:             "Or",
+	FunctionCall:   "FunctionCall",
+	Declaration:    "Declaration",
+	Gets:           "Gets",
+	Variable:       "Variable",
+	If:             "If",
+	ToLoop:         "ToLoop",
+	Return:         "Return",
+	Csv:            "Csv",
+	BigInteger:     "BigInteger",
+	Multiply:       "Multiply",
+	NotEqual:       "NotEqual",
+	GreaterOrEqual: "GreaterOrEqual",
+	LessOrEqual:    "LessOrEqual",
+	And:            "And",
+	Constant:       "Constant",
+	Let:            "Let",
+	Else:           "Else",
+	Spawn:          "Spawn",
+	Join:           "Join",
+	Select:         "Select",
+	SelectCase:     "SelectCase",
+}
+
+func (typ ParseNodeType) String() string {
+	if int(typ) < len(parseNodeTypeNames) {
+		return parseNodeTypeNames[typ]
+	}
+	return "ParseNodeType(" + strconv.Itoa(int(typ)) + ")"
+}
+
+// keyOrder is the order in which Dump prints the tokens and the nodes of a node, the keys that are not listed follow
+// in alphabetical order.
+var keyOrder = []string{Identifier, ReturnType, Parameters, From, To, Children, Otherwise}
+
+// Dump returns the statements starting from root as an indented tree. Every node is printed with its type, its main
+// token and the position of the token, followed by its other tokens and the nodes below it grouped by their keys.
+func Dump(root *ParseNode) string {
+	builder := &strings.Builder{}
+	for node := root; node != nil; node = node.Next() {
+		dump(builder, node, 0)
+	}
+	return builder.String()
+}
+
+func dump(builder *strings.Builder, node *ParseNode, depth int) {
+	indentation := strings.Repeat("  ", depth)
+	if node == nil {
+		builder.WriteString(indentation + "(empty)\n")
+		return
+	}
+	builder.WriteString(indentation + node.NodeType.String())
+	if token := node.MainLexicalToken; token != nil {
+		builder.WriteString(" " + describeToken(token))
+	}
+	builder.WriteString("\n")
+	for _, key := range keys(node) {
+		if token, ok := node.OtherLexicalTokens[key]; ok && token != nil {
+			builder.WriteString(fmt.Sprintf("%s  %s: %s\n", indentation, key, describeToken(token)))
+		}
+		nodes, ok := node.ParseNodes[key]
+		if !ok {
+			continue
+		}
+		builder.WriteString(indentation + "  " + key + ":\n")
+		for _, child := range nodes {
+			if child == nil {
+				dump(builder, nil, depth+2)
+			}
+			// A block is represented by its first statement.
+			for ; child != nil; child = child.Next() {
+				dump(builder, child, depth+2)
+			}
+		}
+	}
+}
+
+func keys(node *ParseNode) []string {
+	present := make(map[string]bool)
+	for key := range node.OtherLexicalTokens {
+		present[key] = true
+	}
+	for key := range node.ParseNodes {
+		present[key] = true
+	}
+	var ordered []string
+	for _, key := range keyOrder {
+		if present[key] {
+			ordered = append(ordered, key)
+			delete(present, key)
+		}
+	}
+	var rest []string
+	for key := range present {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	return append(ordered, rest...)
+}
+
+func describeToken(token *lexer.LexicalToken) string {
+	value := token.Value
+	if token.TokenType == lexer.Text {
+		value = strconv.Quote(value)
+	}
+	return fmt.Sprintf("%s %d:%d", value, token.Line, token.Position)
+}
@@ -2,7 +2,6 @@ package parser
 
 import (
 	"errors"
-	"fmt"
 	"github.com/cevatbarisyilmaz/selinus/lexer"
 	"strconv"
 )
@@ -38,6 +37,7 @@ const (
 	Parameters = "parameters"
 	To         = "to"
 	From       = "from"
+	Otherwise  = "otherwise"
 )
 
 const (
@@ -60,6 +60,19 @@ const (
 	ToLoop
 	Return
 	Csv
+	BigInteger
+	Multiply
+	NotEqual
+	GreaterOrEqual
+	LessOrEqual
+	And
+	Constant
+	Let
+	Else
+	Spawn
+	Join
+	Select
+	SelectCase
 )
 
 type ParseNode struct {
@@ -100,13 +113,23 @@ func Parse(tokens []*lexer.LexicalToken) (*ParseNode, error) {
 		return nil, err
 	}
 	statements := divide(parseTokens)
-	root, err := createParseNodes(statements)
+	root, err := createParseNodes(statements, rootBlock)
 	if err != nil {
 		return nil, err
 	}
 	return root, nil
 }
 
+// ParseInteractive parses a program like Parse, but its last statement can also be an expression, whose value is
+// printed by an interactive session.
+func ParseInteractive(tokens []*lexer.LexicalToken) (*ParseNode, error) {
+	parseTokens, err := group(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return createParseNodes(divide(parseTokens), interactiveBlock)
+}
+
 func group(tokens []*lexer.LexicalToken) ([]*ParseToken, error) {
 	var stack []*ParseToken
 	var inside []*lexer.LexicalToken
@@ -157,12 +180,22 @@ func divide(tokens []*ParseToken) [][]*ParseToken {
 		if e.Group {
 			expecting = false
 			statement = append(statement, e)
+		} else if e.Token.GetType() == lexer.Indent || e.Token.GetType() == lexer.Dedent {
+			expecting = false
+			if len(statement) > 0 {
+				statements = append(statements, statement)
+				statement = make([]*ParseToken, 0)
+			}
+			statements = append(statements, []*ParseToken{e})
 		} else if e.Token.GetType() == lexer.SemiColon || !expecting && e.Token.GetType() == lexer.NewLine {
 			expecting = false
 			if len(statement) > 0 {
 				statements = append(statements, statement)
 				statement = make([]*ParseToken, 0)
 			}
+		} else if e.Token.GetType() == lexer.NewLine {
+			// The statement continues on the next line after a binary operator.
+			continue
 		} else if e.Token.GetType() == lexer.Operator && e.Token.GetValue() != lexer.Increase && e.Token.GetValue() != lexer.Decrease {
 			expecting = true
 			statement = append(statement, e)
@@ -177,14 +210,26 @@ func divide(tokens []*ParseToken) [][]*ParseToken {
 	return statements
 }
 
-func createParseNodes(statements [][]*ParseToken) (*ParseNode, error) {
-	node, _, err := formBlock(statements, 0)
+type blockMode int
+
+const (
+	rootBlock blockMode = iota
+	indentedBlock
+	endedBlock
+	// interactiveBlock is a root block whose last statement can be an expression, see ParseInteractive.
+	interactiveBlock
+)
+
+func createParseNodes(statements [][]*ParseToken, mode blockMode) (*ParseNode, error) {
+	node, _, err := formBlock(statements, 0, mode)
 	return node, err
 }
 
-func getPrecedence(token *ParseToken) int {
+// getPrecedence returns the precedence of a token, the token with the lowest one is the root of its expression. Tokens
+// the lexer accepts but the parser does not support, such as ++, are reported as errors.
+func getPrecedence(token *ParseToken) (int, error) {
 	if token.Group {
-		return 8
+		return 9, nil
 	}
 	switch token.Token.GetType() {
 	case lexer.Keyword:
@@ -199,25 +244,39 @@ func getPrecedence(token *ParseToken) int {
 			fallthrough
 		case lexer.Else:
 			fallthrough
+		case lexer.Const:
+			fallthrough
+		case lexer.Let:
+			fallthrough
+		case lexer.Spawn:
+			fallthrough
+		case lexer.Join:
+			fallthrough
+		case lexer.Select:
+			fallthrough
 		case lexer.Return:
-			return 1
+			return 1, nil
 		case lexer.True:
 			fallthrough
 		case lexer.False:
-			return 7
+			return 8, nil
 		case lexer.As:
 			fallthrough
 		case lexer.To:
-			return 9
+			return 10, nil
 		}
 	case lexer.Operator:
 		switch token.Token.GetValue() {
 		case lexer.Gets:
-			return 2
+			return 2, nil
 		case lexer.Or:
-			return 4
+			return 4, nil
+		case lexer.And:
+			return 5, nil
 		case lexer.Equal:
 			fallthrough
+		case lexer.NotEqual:
+			fallthrough
 		case lexer.Greater:
 			fallthrough
 		case lexer.GreaterOrEqual:
@@ -225,38 +284,84 @@ func getPrecedence(token *ParseToken) int {
 		case lexer.Less:
 			fallthrough
 		case lexer.LessOrEqual:
-			return 5
+			return 6, nil
 		case lexer.Plus:
 			fallthrough
 		case lexer.Minus:
-			return 6
+			return 7, nil
 		case lexer.Multiply:
 			fallthrough
 		case lexer.Divide:
-			return 7
+			return 8, nil
 		}
 	case lexer.Coma:
-		return 3
+		return 3, nil
 	case lexer.Identifier:
 		fallthrough
 	case lexer.Integer:
 		fallthrough
+	case lexer.BigInteger:
+		fallthrough
 	case lexer.Text:
-		return 8
+		return 9, nil
 	}
-	panic(fmt.Sprintf("unknown token %v", token))
+	return 0, errors.New("unexpected token " + token.Token.ToString())
 }
 
-func formBlock(statements [][]*ParseToken, i int) (*ParseNode, int, error) {
+func isStatementOf(statement []*ParseToken, tokenType lexer.TokenType, value string) bool {
+	token := statement[0]
+	if token.Group || token.Token.GetType() != tokenType {
+		return false
+	}
+	return value == "" || token.Token.GetValue() == value
+}
+
+// formBlock links the statements starting from i into a block and returns the index of the first statement after
+// it. Indented blocks are closed by a dedent, optionally preceded or followed by an end. Blocks that are not
+// indented are closed by an end or a return.
+func formBlock(statements [][]*ParseToken, i int, mode blockMode) (*ParseNode, int, error) {
 	var root *ParseNode
 	var temp *ParseNode
 	var pre *ParseNode
 	var err error
-	for length := len(statements); i < length; i++ {
-		if statements[i][0].Token.GetType() == lexer.Keyword && statements[i][0].Token.GetValue() == lexer.End {
-			return root, i, nil
+	for length := len(statements); i < length; {
+		statement := statements[i]
+		if isStatementOf(statement, lexer.Dedent, "") {
+			switch mode {
+			case indentedBlock:
+				return root, i + 1, nil
+			case endedBlock:
+				return root, i, nil
+			}
+			return nil, i, errors.New("unexpected dedent" + statement[0].Token.ToString())
+		}
+		if isStatementOf(statement, lexer.Indent, "") {
+			return nil, i, errors.New("unexpected indentation" + statement[0].Token.ToString())
+		}
+		if isStatementOf(statement, lexer.Keyword, lexer.Else) {
+			if mode == endedBlock {
+				return root, i, nil
+			}
+			return nil, i, errors.New("unexpected else " + statement[0].Token.ToString())
+		}
+		if isStatementOf(statement, lexer.Keyword, lexer.End) {
+			switch mode {
+			case indentedBlock:
+				if i+1 < length && !isStatementOf(statements[i+1], lexer.Dedent, "") {
+					return nil, i, errors.New("expected the block to end after " + statement[0].Token.ToString())
+				}
+				return root, i + 2, nil
+			case endedBlock:
+				return root, i + 1, nil
+			}
+			return nil, i, errors.New("unexpected end " + statement[0].Token.ToString())
+		}
+		temp, err = formParseNode(statement, true)
+		if err != nil && mode == interactiveBlock && i == length-1 {
+			if expression, expressionErr := formParseNode(statement, false); expressionErr == nil {
+				temp, err = expression, nil
+			}
 		}
-		temp, err = formParseNode(statements[i], true)
 		if err != nil {
 			return nil, i, err
 		}
@@ -267,26 +372,166 @@ func formBlock(statements [][]*ParseToken, i int) (*ParseNode, int, error) {
 			pre.next = temp
 		}
 		pre = temp
-		if temp.NodeType == Return {
+		i++
+		if temp.NodeType == Return && mode == endedBlock {
 			return root, i, nil
 		}
 		if temp.NodeType == If || temp.NodeType == ToLoop || temp.NodeType == Function {
-			child, a, err := formBlock(statements, i+1)
-			i = a
+			i, err = formBody(temp, statements, i)
+			if err != nil {
+				return nil, i, err
+			}
+		}
+		if temp.NodeType == Select {
+			i, err = formCases(temp, statements, i)
 			if err != nil {
 				return nil, i, err
 			}
-			temp.ParseNodes[Children] = append(temp.ParseNodes[Children], child)
 		}
 	}
 	return root, i, nil
 }
 
+// formBody forms the block that follows the header of a function, condition or loop and, for conditions, the else
+// branch after it. It returns the index of the first statement after them.
+func formBody(header *ParseNode, statements [][]*ParseToken, i int) (int, error) {
+	var child *ParseNode
+	var err error
+	length := len(statements)
+	if i < length && isStatementOf(statements[i], lexer.Indent, "") {
+		child, i, err = formBlock(statements, i+1, indentedBlock)
+		if err != nil {
+			return i, err
+		}
+		if i < length && isStatementOf(statements[i], lexer.Keyword, lexer.End) && !isStatementOf(statements[i-2], lexer.Keyword, lexer.End) {
+			i++
+		}
+	} else {
+		child, i, err = formBlock(statements, i, endedBlock)
+		if err != nil {
+			return i, err
+		}
+	}
+	header.ParseNodes[Children] = append(header.ParseNodes[Children], child)
+	if header.NodeType != If || i >= length || !isStatementOf(statements[i], lexer.Keyword, lexer.Else) {
+		return i, nil
+	}
+	elseStatement := statements[i]
+	i++
+	if len(elseStatement) == 1 {
+		otherwise := &ParseNode{NodeType: Else, ParseNodes: map[string][]*ParseNode{}, MainLexicalToken: elseStatement[0].Token}
+		i, err = formBody(otherwise, statements, i)
+		if err != nil {
+			return i, err
+		}
+		header.ParseNodes[Otherwise] = otherwise.ParseNodes[Children]
+		return i, nil
+	}
+	if elseStatement[1].Group || elseStatement[1].Token.GetType() != lexer.Keyword || elseStatement[1].Token.GetValue() != lexer.If {
+		return i, errors.New("expected if or the end of the line after " + elseStatement[0].Token.ToString())
+	}
+	condition, err := formParseNode(elseStatement[1:], true)
+	if err != nil {
+		return i, err
+	}
+	i, err = formBody(condition, statements, i)
+	if err != nil {
+		return i, err
+	}
+	header.ParseNodes[Otherwise] = []*ParseNode{condition}
+	return i, nil
+}
+
+// formCases forms the indented cases that follow a select and returns the index of the first statement after them.
+// Every case is a receive or a send, optionally followed by an indented body, and an else case may come last.
+func formCases(header *ParseNode, statements [][]*ParseToken, i int) (int, error) {
+	length := len(statements)
+	if i >= length || !isStatementOf(statements[i], lexer.Indent, "") {
+		return i, errors.New("expected indented cases after " + header.MainLexicalToken.ToString())
+	}
+	i++
+	for i < length {
+		statement := statements[i]
+		if isStatementOf(statement, lexer.Dedent, "") {
+			return i + 1, nil
+		}
+		if isStatementOf(statement, lexer.Keyword, lexer.End) {
+			if i+1 < length && !isStatementOf(statements[i+1], lexer.Dedent, "") {
+				return i, errors.New("expected the cases to end after " + statement[0].Token.ToString())
+			}
+			return i + 2, nil
+		}
+		if _, ok := header.ParseNodes[Otherwise]; ok {
+			return i, errors.New("unexpected case after else " + statement[0].GetStartPosition())
+		}
+		var c *ParseNode
+		var err error
+		if isStatementOf(statement, lexer.Keyword, lexer.Else) {
+			if len(statement) != 1 {
+				return i, errors.New("unexpected " + statement[1].GetStartPosition())
+			}
+			c = &ParseNode{NodeType: Else, ParseNodes: map[string][]*ParseNode{}, MainLexicalToken: statement[0].Token}
+		} else {
+			c, err = formCase(statement)
+			if err != nil {
+				return i, err
+			}
+		}
+		i++
+		if i < length && isStatementOf(statements[i], lexer.Indent, "") {
+			i, err = formBody(c, statements, i)
+			if err != nil {
+				return i, err
+			}
+		} else {
+			c.ParseNodes[Children] = []*ParseNode{nil}
+		}
+		if c.NodeType == Else {
+			header.ParseNodes[Otherwise] = c.ParseNodes[Children]
+		} else {
+			header.ParseNodes[Children] = append(header.ParseNodes[Children], c)
+		}
+	}
+	return i, nil
+}
+
+// formCase forms a case of a select, a call to receive or send that may bind the received value with as.
+func formCase(tokens []*ParseToken) (*ParseNode, error) {
+	operation := tokens
+	var identifier *lexer.LexicalToken
+	for i, token := range tokens {
+		if !token.Group && token.Token.GetType() == lexer.Keyword && token.Token.GetValue() == lexer.As {
+			if i+2 != len(tokens) || tokens[i+1].Group || tokens[i+1].Token.GetType() != lexer.Identifier {
+				return nil, errors.New("was expecting an identifier after " + token.GetEndPosition())
+			}
+			operation = tokens[:i]
+			identifier = tokens[i+1].Token
+			break
+		}
+	}
+	node, err := formParseNode(operation, true)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || node.NodeType != FunctionCall {
+		return nil, errors.New("expected receive or send " + tokens[0].GetStartPosition())
+	}
+	return &ParseNode{
+		NodeType:           SelectCase,
+		ParseNodes:         map[string][]*ParseNode{Parameters: node.ParseNodes[Parameters]},
+		MainLexicalToken:   node.MainLexicalToken,
+		OtherLexicalTokens: map[string]*lexer.LexicalToken{Identifier: identifier},
+	}, nil
+}
+
 func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 	currentPrecedence := -1
 	var currentIndex int
 	for i, t := range tokens {
-		precedence := getPrecedence(t)
+		precedence, err := getPrecedence(t)
+		if err != nil {
+			return nil, err
+		}
 		if currentPrecedence == -1 || precedence < currentPrecedence {
 			currentPrecedence = precedence
 			currentIndex = i
@@ -338,7 +583,7 @@ func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 			MainLexicalToken: t2,
 		}, nil
 	case lexer.Operator:
-		if isStatement {
+		if isStatement && t2.GetValue() != lexer.Gets {
 			return nil, errors.New("was expecting a statement " + t.GetStartPosition())
 		}
 		var leftChild *ParseNode
@@ -371,14 +616,24 @@ func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 			nodeType = Summation
 		case lexer.Minus:
 			nodeType = Subtraction
+		case lexer.Multiply:
+			nodeType = Multiply
 		case lexer.Equal:
 			nodeType = Equal
+		case lexer.NotEqual:
+			nodeType = NotEqual
 		case lexer.Or:
 			nodeType = Or
+		case lexer.And:
+			nodeType = And
 		case lexer.Greater:
 			nodeType = Greater
 		case lexer.Less:
 			nodeType = Less
+		case lexer.GreaterOrEqual:
+			nodeType = GreaterOrEqual
+		case lexer.LessOrEqual:
+			nodeType = LessOrEqual
 		case lexer.Divide:
 			nodeType = Divide
 		}
@@ -395,6 +650,11 @@ func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 			return nil, errors.New("unexpected token " + tokens[1].Token.ToString())
 		}
 		return &ParseNode{NodeType: Integer, MainLexicalToken: t2}, nil
+	case lexer.BigInteger:
+		if len(tokens) != 1 {
+			return nil, errors.New("unexpected token " + tokens[1].Token.ToString())
+		}
+		return &ParseNode{NodeType: BigInteger, MainLexicalToken: t2}, nil
 	case lexer.Identifier:
 		if len(tokens) > 1 {
 			if !tokens[1].Group && tokens[1].Token.GetType() == lexer.Identifier {
@@ -502,6 +762,60 @@ func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 					Identifier: asIdentifier,
 				},
 			}, nil
+		case lexer.Const:
+			fallthrough
+		case lexer.Let:
+			if currentIndex != 0 || !isStatement {
+				return nil, errors.New("unexpected " + t.GetStartPosition())
+			}
+			if len(tokens) == 1 {
+				return nil, errors.New("expected a declaration after " + t2.ToString())
+			}
+			binding, err := formParseNode(tokens[1:], false)
+			if err != nil {
+				return nil, err
+			}
+			if binding == nil || binding.NodeType != Gets {
+				return nil, errors.New("expected an initializer after " + t2.ToString())
+			}
+			target := binding.GetParseNodesWithKey(Children)[0]
+			if target == nil || target.NodeType != Variable && target.NodeType != Declaration {
+				return nil, errors.New("expected an identifier after " + t2.ToString())
+			}
+			nodeType := Let
+			if t2.GetValue() == lexer.Const {
+				nodeType = Constant
+			}
+			return &ParseNode{NodeType: nodeType, ParseNodes: binding.ParseNodes, MainLexicalToken: t2}, nil
+		case lexer.Spawn:
+			if currentIndex != 0 || !isStatement {
+				return nil, errors.New("unexpected " + t.GetStartPosition())
+			}
+			if len(tokens) == 1 {
+				return nil, errors.New("expected a function call after " + t2.ToString())
+			}
+			call, err := formParseNode(tokens[1:], false)
+			if err != nil {
+				return nil, err
+			}
+			if call.NodeType != FunctionCall {
+				return nil, errors.New("expected a function call after " + t2.ToString())
+			}
+			return &ParseNode{NodeType: Spawn, ParseNodes: map[string][]*ParseNode{Children: {call}}, MainLexicalToken: t2}, nil
+		case lexer.Join:
+			fallthrough
+		case lexer.Select:
+			if currentIndex != 0 || !isStatement {
+				return nil, errors.New("unexpected " + t.GetStartPosition())
+			}
+			if len(tokens) > 1 {
+				return nil, errors.New("unexpected " + tokens[1].GetStartPosition())
+			}
+			nodeType := Join
+			if t2.GetValue() == lexer.Select {
+				nodeType = Select
+			}
+			return &ParseNode{NodeType: nodeType, ParseNodes: map[string][]*ParseNode{}, MainLexicalToken: t2}, nil
 		case lexer.If:
 			if currentIndex != 0 {
 				return nil, errors.New("unexpected " + t.GetStartPosition())
@@ -535,10 +849,11 @@ func formParseNode(tokens []*ParseToken, isStatement bool) (*ParseNode, error) {
 							return nil, err
 						}
 						var children []*ParseNode
-						switch parametersNode.NodeType {
-						case Declaration:
+						switch {
+						case parametersNode == nil:
+						case parametersNode.NodeType == Declaration:
 							children = append(children, parametersNode)
-						case Csv:
+						case parametersNode.NodeType == Csv:
 							for _, child := range parametersNode.GetParseNodesWithKey(Children) {
 								if child.NodeType != Declaration {
 									return nil, errors.New("was expecting parameter declaration " + child.GetMainToken().ToString())
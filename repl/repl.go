@@ -0,0 +1,247 @@
+// Package repl reads programs from a stream and evaluates them one at a time on the same interpreter, so that every
+// input can use the declarations of the previous ones.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+const help = `Enter statements to run them. A line that starts a function, an if, a loop or a select without being
+indented is continued until an end that is not indented closes it. The value of an expression is printed with its
+type. exit(code) ends the session with the given exit status.
+:type expr    prints the type of the expression without running it
+:load file    runs the file
+:reset        forgets every declaration
+:history      prints the previous inputs
+:help         prints this message
+:quit         exits
+`
+
+// REPL is a read-eval-print loop.
+type REPL struct {
+	input       *bufio.Reader
+	output      io.Writer
+	options     []runner.Option
+	interpreter *runner.Interpreter
+	// history are the lines entered so far, including the ones read from historyPath. New lines are appended to the
+	// file at historyPath unless it is empty.
+	history     []string
+	historyPath string
+}
+
+// New returns a REPL that reads from input and writes the prompts, the results and the output of the programs to
+// output. The programs read their input from input as well. The interpreters of the REPL are created with opts.
+func New(input io.Reader, output io.Writer, historyPath string, opts ...runner.Option) (*REPL, error) {
+	r := &REPL{input: bufio.NewReader(input), output: output, historyPath: historyPath}
+	r.options = append(append([]runner.Option{}, opts...), runner.WithStdin(r.input), runner.WithStdout(output))
+	if err := r.reset(); err != nil {
+		return nil, err
+	}
+	if historyPath != "" {
+		data, err := os.ReadFile(historyPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(data) > 0 {
+			r.history = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		}
+	}
+	return r, nil
+}
+
+func (r *REPL) reset() error {
+	interpreter, err := runner.New(r.options...)
+	if err != nil {
+		return err
+	}
+	r.interpreter = interpreter
+	return nil
+}
+
+// Run evaluates the inputs until the input ends, :quit is entered or a program calls exit. The exit of a program is
+// returned as a *runner.Exit.
+func (r *REPL) Run() error {
+	for {
+		src, err := r.read()
+		if src == "" && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if strings.TrimSpace(src) == ":quit" {
+			return nil
+		}
+		if err := r.remember(src); err != nil {
+			fmt.Fprintln(r.output, "History error:", err)
+		}
+		if exit := r.evaluate(src); exit != nil {
+			return exit
+		}
+	}
+}
+
+// read reads an input, which spans several lines when its first line starts a block that is closed with end later.
+func (r *REPL) read() (string, error) {
+	var lines []string
+	depth := 0
+	for {
+		if len(lines) == 0 {
+			fmt.Fprint(r.output, prompt)
+		} else {
+			fmt.Fprint(r.output, continuationPrompt)
+		}
+		line, err := r.input.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" || err == nil {
+			lines = append(lines, line)
+			depth += blocks(line)
+		}
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		if depth <= 0 {
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+}
+
+// blocks returns 1 for a line that starts a block, -1 for a line that ends one and 0 otherwise. Only the lines that
+// are not indented are counted, the blocks nested in an indented body end with their indentation.
+func blocks(line string) int {
+	if line == "" || line[0] == ' ' || line[0] == '\t' {
+		return 0
+	}
+	word := line
+	if end := strings.IndexFunc(line, func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	}); end >= 0 {
+		word = line[:end]
+	}
+	switch word {
+	case "func", "if", "loop", "select":
+		return 1
+	case "end":
+		if strings.TrimSpace(line) == "end" {
+			return -1
+		}
+	}
+	return 0
+}
+
+func (r *REPL) remember(src string) error {
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	lines := strings.Split(src, "\n")
+	r.history = append(r.history, lines...)
+	if r.historyPath == "" {
+		return nil
+	}
+	file, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(src + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// evaluate evaluates an input and prints its result, it returns the exit of a program that called exit. Go panics
+// raised while evaluating an input are printed like errors, so that no input ends the session.
+func (r *REPL) evaluate(src string) (exit *runner.Exit) {
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Fprintln(r.output, "internal error:", p)
+			exit = nil
+		}
+	}()
+	command, argument := src, ""
+	if i := strings.IndexAny(src, " \t"); i >= 0 {
+		command, argument = src[:i], strings.TrimSpace(src[i+1:])
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	var err error
+	switch command {
+	case ":type":
+		var typ *core.Type
+		if argument != "" {
+			typ, err = r.interpreter.TypeOf(argument)
+		}
+		if err == nil && typ == nil {
+			err = errors.New(":type expects an expression that has a value")
+		}
+		if err == nil {
+			fmt.Fprintln(r.output, typ.Name)
+		}
+	case ":load":
+		if argument == "" {
+			err = errors.New(":load expects a file")
+		} else {
+			err = r.interpreter.EvalFile(argument)
+		}
+	case ":reset":
+		err = r.reset()
+	case ":history":
+		for i, line := range r.history {
+			fmt.Fprintf(r.output, "%5d  %s\n", i+1, line)
+		}
+	case ":help":
+		fmt.Fprint(r.output, help)
+	default:
+		if strings.HasPrefix(command, ":") {
+			err = errors.New("unknown command " + command + ", enter :help for the list of commands")
+			break
+		}
+		var value *core.Pointer
+		var typ *core.Type
+		value, typ, err = r.interpreter.Evaluate(src)
+		if err == nil && typ != nil {
+			fmt.Fprintln(r.output, describe(value, typ))
+		}
+	}
+	if errors.As(err, &exit) {
+		return exit
+	}
+	if err != nil {
+		fmt.Fprintln(r.output, err)
+	}
+	return nil
+}
+
+// describe returns the value followed by the name of its type. Strings are quoted, the values that can not be
+// converted to strings are described as Go values.
+func describe(value *core.Pointer, typ *core.Type) string {
+	if value == nil || value.Variable == nil {
+		return "nothing (" + typ.Name + ")"
+	}
+	if typ == core.VariableType {
+		typ = value.Variable.GetType()
+	}
+	if s, ok := value.Variable.VariableInterface.(*builtin.String); ok {
+		return strconv.Quote(s.Value) + " (" + typ.Name + ")"
+	}
+	text, exception := builtin.ToString(value)
+	if exception != nil {
+		text = fmt.Sprint(builtin.GoValueOf(value))
+	}
+	return text + " (" + typ.Name + ")"
+}
@@ -0,0 +1,93 @@
+package repl_test
+
+import (
+	"errors"
+	"github.com/cevatbarisyilmaz/selinus/repl"
+	"github.com/cevatbarisyilmaz/selinus/runner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, input string, historyPath string) string {
+	output := &strings.Builder{}
+	r, err := repl.New(strings.NewReader(input), output, historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestREPL(t *testing.T) {
+	directory := t.TempDir()
+	program := filepath.Join(directory, "program.selinus")
+	if err := os.WriteFile(program, []byte("int loaded = 5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		"1 + 2",
+		"func int square(int n)",
+		"\treturn n * n",
+		"end",
+		"square(4)",
+		"if square(2) == 4",
+		"println(\"four\")",
+		"end",
+		"\"a\" + \"b\"",
+		":type square(2) > 3",
+		":load " + program,
+		"loaded",
+		"1 / 0",
+		"loaded++",
+		"!loaded",
+		":reset",
+		"square(1)",
+		":quit",
+		"unreachable",
+	}, "\n") + "\n"
+	expected := "> 3 (Integer)\n" +
+		"> ... ... > 16 (Integer)\n" +
+		"> ... ... four\n" +
+		"> \"ab\" (String)\n" +
+		"> Boolean\n" +
+		"> > 5 (Integer)\n" +
+		"> division by zero\n/ at line 1 position 3 at file eval\n" +
+		"> Parsing error: unexpected token ++ at line 1 position 7 at file eval\n" +
+		"> Scanning error: unknown operator ! at line 1 position 1 at file eval\n" +
+		"> > Compile error: function square is not defined square at line 1 position 1 at file eval\n" +
+		"> "
+	if output := run(t, input, ""); output != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestREPLHistory(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history")
+	run(t, "int x = 1\nfunc f()\n\tprintln(\"f\")\nend\n", historyPath)
+	output := run(t, ":history\n", historyPath)
+	expected := "> " +
+		"    1  int x = 1\n" +
+		"    2  func f()\n" +
+		"    3  \tprintln(\"f\")\n" +
+		"    4  end\n" +
+		"    5  :history\n" +
+		"> "
+	if output != expected {
+		t.Fatalf("output mismatch, expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestREPLExit(t *testing.T) {
+	r, err := repl.New(strings.NewReader("println(\"a\")\nexit(3)\nprintln(\"b\")\n"), &strings.Builder{}, "", runner.WithOS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exit *runner.Exit
+	if err := r.Run(); !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatal("unexpected error ", err)
+	}
+}
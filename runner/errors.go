@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// ReadError is returned when a program can not be read.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return "Read error: " + e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// ScanError is returned when a program can not be split into tokens.
+type ScanError struct {
+	Err error
+}
+
+func (e *ScanError) Error() string {
+	return "Scanning error: " + e.Err.Error()
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when the tokens of a program do not form valid statements.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return "Parsing error: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// CompileError is returned when a program is rejected by the compiler, e.g. because of a type mismatch.
+type CompileError struct {
+	Err error
+}
+
+func (e *CompileError) Error() string {
+	return "Compile error: " + e.Err.Error()
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError is returned when a compiled program can not be loaded.
+type LoadError struct {
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	return "Load error: " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// RuntimeError is an exception raised by a running program. Positions lists where the exception was raised followed
+// by the calls that lead there. Scheduled is set when the program ran on a scheduler, see WithSeed, and Seed is the
+// seed that replays the run then.
+type RuntimeError struct {
+	Message   string
+	Positions []string
+	Scheduled bool
+	Seed      int64
+}
+
+// Exit is returned when a program ended itself with exit, Code is the exit status it gave.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func newRuntimeError(exception *core.Return) *RuntimeError {
+	trace := exception.Pointer.Variable.VariableInterface.(*core.StackTrace)
+	return &RuntimeError{Message: trace.ExceptionMessage, Positions: trace.Positions}
+}
+
+// Error returns the message followed by the stack trace and the seed of a scheduled run, as it is printed by Run.
+func (e *RuntimeError) Error() string {
+	trace := (&core.StackTrace{ExceptionMessage: e.Message, Positions: e.Positions}).GetStringValue()
+	if e.Scheduled {
+		trace += fmt.Sprintf("\nreplay with seed %d", e.Seed)
+	}
+	return trace
+}
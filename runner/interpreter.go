@@ -0,0 +1,287 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"github.com/cevatbarisyilmaz/selinus/executer"
+	osmodule "github.com/cevatbarisyilmaz/selinus/library/os"
+	"github.com/cevatbarisyilmaz/selinus/library/standard"
+	"github.com/cevatbarisyilmaz/selinus/module"
+	"github.com/cevatbarisyilmaz/selinus/parser"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Interpreter runs Selinus programs for a Go host. Programs evaluated by the same interpreter share their top level
+// declarations, so a function declared by one can be called by the next one or by Call. An Interpreter is not safe for
+// concurrent use, but separate interpreters share no mutable state and can run in parallel goroutines.
+type Interpreter struct {
+	options *options
+	scope   *core.Scope
+	// stdin buffers the input of the interpreter, it is shared by all evaluations.
+	stdin io.Reader
+}
+
+// New returns an interpreter with the standard library imported, and the os module when WithOS is given.
+func New(opts ...Option) (*Interpreter, error) {
+	i := &Interpreter{options: newOptions(opts), scope: getBaseScope()}
+	if i.options.stdin != nil {
+		i.stdin = i.options.stdin
+		if _, ok := i.stdin.(io.RuneScanner); !ok {
+			i.stdin = bufio.NewReader(i.stdin)
+		}
+	}
+	modules := []*module.Module{standard.Module}
+	if i.options.os {
+		modules = append(modules, osmodule.Module)
+	}
+	for _, module := range modules {
+		if err := i.importModule(module); err != nil {
+			return nil, err
+		}
+	}
+	i.scope.CreateBlock()
+	return i, nil
+}
+
+// Eval runs the source code of a program.
+func (i *Interpreter) Eval(src string) error {
+	return i.eval("eval", src)
+}
+
+// Evaluate runs the source code of a program like Eval. When the last statement of the program is an expression that
+// has a value, it returns the value and the type the compiler gave the expression, and nil otherwise.
+func (i *Interpreter) Evaluate(src string) (*core.Pointer, *core.Type, error) {
+	rootParseNode, err := parseInteractive("eval", src)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootCompileNode, typ, err := i.compile(rootParseNode)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := i.run(func() *core.Return {
+		return i.execute(rootCompileNode)
+	})
+	if err != nil || typ == nil {
+		return nil, nil, err
+	}
+	return value, typ, nil
+}
+
+// TypeOf returns the type the compiler gives the expression without running it, or nil when the last statement of
+// the source code is not an expression that has a value. The declarations of the source code are forgotten.
+func (i *Interpreter) TypeOf(src string) (*core.Type, error) {
+	rootParseNode, err := parseInteractive("eval", src)
+	if err != nil {
+		return nil, err
+	}
+	references := i.references()
+	defer func() {
+		i.scope.CurrentBlock().References = references
+	}()
+	_, typ, err := i.compile(rootParseNode)
+	return typ, err
+}
+
+// EvalFile runs the program stored at filePath, which is either source code or a program built with Build.
+func (i *Interpreter) EvalFile(filePath string) error {
+	if filepath.Ext(filePath) == vm.Extension {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return &ReadError{Err: err}
+		}
+		program, err := vm.Load(data, i.scope)
+		if err != nil {
+			return &LoadError{Err: err}
+		}
+		_, err = i.run(func() *core.Return {
+			return vm.Run(program, i.scope)
+		})
+		return err
+	}
+	return i.eval(filePath, "")
+}
+
+// Call calls the function declared with the given name at the top level of an evaluated program or by the standard
+// library and returns its result. Arguments and results are converted with builtin.FromGoValue and builtin.GoValueOf.
+func (i *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	pointer := i.scope.MustGet(name)
+	if pointer == nil {
+		return nil, fmt.Errorf("%s is not declared", name)
+	}
+	if pointer.Slot != nil {
+		pointer = i.scope.Frame.Slots[pointer.Slot.Index]
+	}
+	function, exception := core.FunctionOf(pointer, name)
+	if exception != nil {
+		return nil, newRuntimeError(exception)
+	}
+	parameters := function.GetParameters()
+	if len(args) > len(parameters) {
+		return nil, fmt.Errorf("%s takes %d arguments but %d were given", name, len(parameters), len(args))
+	}
+	arguments := make([]*core.Pointer, len(args))
+	for index, arg := range args {
+		argument, err := builtin.FromGoValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !argument.Typ.IsCompatible(parameters[index].Typ) {
+			return nil, fmt.Errorf("parameter %s of %s expects %s but got %s", parameters[index].Name, name, parameters[index].Typ.Name, argument.Typ.Name)
+		}
+		arguments[index] = argument
+	}
+	res, err := i.run(func() *core.Return {
+		return core.Call(i.scope.Thread, function, arguments, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return builtin.GoValueOf(res), nil
+}
+
+// Register declares a Go function under the given name for the programs evaluated afterwards. Its Selinus signature
+// is derived from the signature of fn as described by builtin.NewFunctionPointer.
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	if existing := i.scope.MustGetFromCurrentBlock(name); existing != nil && existing.Immutable {
+		return fmt.Errorf("%s is already declared", name)
+	}
+	pointer, err := builtin.NewFunctionPointer(name, fn)
+	if err != nil {
+		return err
+	}
+	i.scope.DeclareAndSet(name, pointer)
+	return nil
+}
+
+func (i *Interpreter) eval(filePath, fileContent string) error {
+	rootParseNode, err := parse(filePath, fileContent)
+	if err != nil {
+		return err
+	}
+	rootCompileNode, _, err := i.compile(rootParseNode)
+	if err != nil {
+		return err
+	}
+	_, err = i.run(func() *core.Return {
+		return i.execute(rootCompileNode)
+	})
+	return err
+}
+
+// compile compiles a program against the scope of the interpreter and returns the type of its result, see
+// compiler.CompileResult. The declarations of a program that does not compile are forgotten, so that it can be
+// corrected and evaluated again.
+func (i *Interpreter) compile(rootParseNode *parser.ParseNode) (core.Node, *core.Type, error) {
+	references := i.references()
+	rootCompileNode, typ, err := compiler.CompileResult(rootParseNode, i.scope)
+	if err != nil {
+		i.scope.CurrentBlock().References = references
+		return nil, nil, &CompileError{Err: err}
+	}
+	return rootCompileNode, typ, nil
+}
+
+// references returns a copy of the declarations at the top level of the interpreter.
+func (i *Interpreter) references() map[string]*core.Pointer {
+	block := i.scope.CurrentBlock()
+	references := make(map[string]*core.Pointer, len(block.References))
+	for name, pointer := range block.References {
+		references[name] = pointer
+	}
+	return references
+}
+
+func (i *Interpreter) execute(root core.Node) *core.Return {
+	if i.options.backend == VirtualMachine {
+		return vm.Run(compiler.CompileBytecode(root), i.scope)
+	}
+	return executer.Execute(root, i.scope)
+}
+
+// run runs f on a new thread, so that every evaluation and call gets the full limits of the interpreter. It returns once
+// the tasks f spawned are done too. When f raises an exception, the tasks are cancelled rather than waited for. A task
+// that calls exit ends the run with its exit status, and the exception of a task is reported rather than a deadlock or
+// a cancellation it caused in f. Every run of an interpreter created with WithSeed gets a scheduler with the same seed.
+func (i *Interpreter) run(f func() *core.Return) (*core.Pointer, error) {
+	ctx, cancel := context.WithCancel(i.options.context)
+	defer cancel()
+	if i.options.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, i.options.timeout)
+		defer cancel()
+	}
+	i.scope.Thread = &core.Thread{
+		Context:      ctx,
+		MaxDepth:     i.options.maxCallDepth,
+		MaxSteps:     i.options.maxSteps,
+		MaxAllocated: i.options.maxMemory,
+		Stdin:        i.stdin,
+		Stdout:       i.options.stdout,
+		Stderr:       i.options.stderr,
+		Args:         i.options.args,
+	}
+	if i.options.scheduled {
+		i.scope.Thread.Scheduler = core.NewScheduler(i.options.seed)
+	}
+	defer func() {
+		i.scope.Thread = nil
+	}()
+	res := f()
+	if res.ReturnType == core.EXCEPTION {
+		cancel()
+		if exception := i.scope.Thread.Join(); exception != nil && core.Interrupted(res) {
+			res = exception
+		}
+		if exited := i.scope.Thread.Exited(); exited != nil {
+			res = exited
+		}
+		return nil, i.runtimeError(res)
+	}
+	if exception := i.scope.Thread.Join(); exception != nil {
+		return nil, i.runtimeError(exception)
+	}
+	return res.Pointer, nil
+}
+
+// runtimeError returns the error for an exception raised by a run, with the seed it can be replayed with, or an Exit
+// when the program called exit.
+func (i *Interpreter) runtimeError(exception *core.Return) error {
+	if trace := exception.Pointer.Variable.VariableInterface.(*core.StackTrace); trace.Exit {
+		return &Exit{Code: trace.ExitCode}
+	}
+	err := newRuntimeError(exception)
+	if i.options.scheduled {
+		err.Scheduled = true
+		err.Seed = i.options.seed
+	}
+	return err
+}
+
+// importModule runs the root file of the module in a new block of the scope. The compiled root file is loaded when the
+// module has one, whatever the backend, and the source is compiled only when it can not be loaded. The tree walker
+// calls the functions of a loaded module like any other function, they run on the virtual machine. A module without a
+// root file only declares its natives.
+func (i *Interpreter) importModule(module *module.Module) error {
+	i.scope.AddBlock(module.NativeBlock)
+	i.scope.CreateBlock()
+	if module.RootFile == "" {
+		return nil
+	}
+	if module.Compiled != nil {
+		program, err := vm.Load(module.Compiled, i.scope)
+		if err == nil {
+			_, err = i.run(func() *core.Return {
+				return vm.Run(program, i.scope)
+			})
+			return err
+		}
+	}
+	return i.eval(module.Name, module.RootFile)
+}
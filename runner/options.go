@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"context"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"io"
+	"time"
+)
+
+// Backend executes compiled programs.
+type Backend int
+
+const (
+	// TreeWalker executes the compiled tree directly.
+	TreeWalker Backend = iota
+	// VirtualMachine translates the compiled tree to bytecode and runs it on a stack machine.
+	VirtualMachine
+)
+
+type options struct {
+	backend      Backend
+	maxCallDepth int
+	context      context.Context
+	timeout      time.Duration
+	maxSteps     int64
+	maxMemory    int64
+	stdin        io.Reader
+	stdout       io.Writer
+	stderr       io.Writer
+	scheduled    bool
+	seed         int64
+	args         []string
+	os           bool
+}
+
+// Option configures an Interpreter or a run.
+type Option func(*options)
+
+// WithBackend selects the backend that executes the program, the tree walker is used by default.
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithMaxCallDepth sets the number of nested calls after which a stack overflow exception is raised, calls in tail
+// position do not count. core.DefaultMaxCallDepth is used by default.
+func WithMaxCallDepth(depth int) Option {
+	return func(o *options) {
+		o.maxCallDepth = depth
+	}
+}
+
+// WithContext stops the program with an exception once ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.context = ctx
+	}
+}
+
+// WithTimeout stops the program with an exception once it has run for the given duration. Each evaluation and call of
+// an Interpreter gets the full duration.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithMaxSteps stops the program with an exception after the given number of function calls and loop iterations.
+// Like the other limits, it applies to each evaluation and call of an Interpreter separately.
+func WithMaxSteps(steps int64) Option {
+	return func(o *options) {
+		o.maxSteps = steps
+	}
+}
+
+// WithMaxMemory stops the program with an exception once the strings, big integers, sets and channel buffers it created
+// add up to the given number of bytes.
+func WithMaxMemory(bytes int64) Option {
+	return func(o *options) {
+		o.maxMemory = bytes
+	}
+}
+
+// WithStdin sets the stream programs read from, the standard input of the process is used by default.
+func WithStdin(reader io.Reader) Option {
+	return func(o *options) {
+		o.stdin = reader
+	}
+}
+
+// WithStdout sets the stream print writes to, the standard output of the process is used by default.
+func WithStdout(writer io.Writer) Option {
+	return func(o *options) {
+		o.stdout = writer
+	}
+}
+
+// WithStderr sets the stream eprint writes to, the standard error of the process is used by default.
+func WithStderr(writer io.Writer) Option {
+	return func(o *options) {
+		o.stderr = writer
+	}
+}
+
+// WithSeed runs the tasks a program spawns one at a time on a core.Scheduler seeded with seed instead of in parallel,
+// so that a run that fails can be replayed with the seed. The seed is reported by the RuntimeError of a failed run.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.scheduled = true
+		o.seed = seed
+	}
+}
+
+// WithArgs sets the arguments programs are started with, which they read with the args and arg functions of the os
+// module.
+func WithArgs(args []string) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+// WithOS imports the os module, which gives programs the arguments set with WithArgs, the environment variables and
+// the working directory of the process and the exit function. Interpreters do not import it by default, so that
+// embedded programs can not read the environment of their host.
+func WithOS() Option {
+	return func(o *options) {
+		o.os = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{backend: TreeWalker, maxCallDepth: core.DefaultMaxCallDepth, context: context.Background()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -2,81 +2,248 @@ package runner
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/cevatbarisyilmaz/selinus/compiler"
 	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
 	"github.com/cevatbarisyilmaz/selinus/compiler/core"
-	"github.com/cevatbarisyilmaz/selinus/executer"
 	"github.com/cevatbarisyilmaz/selinus/lexer"
-	"github.com/cevatbarisyilmaz/selinus/library/standard"
+	"github.com/cevatbarisyilmaz/selinus/lint"
 	"github.com/cevatbarisyilmaz/selinus/module"
 	"github.com/cevatbarisyilmaz/selinus/parser"
 	"github.com/cevatbarisyilmaz/selinus/reader"
+	"github.com/cevatbarisyilmaz/selinus/vm"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 )
 
-func Run(filePath, fileContent string) int {
-	var stream *bufio.Reader
-	var err error
-	if fileContent == "" {
-		stream, err = reader.ReadFile(filePath)
-	} else {
-		stream = reader.ReadString(fileContent)
+// Exit codes returned by Run, Build, Check and Test. Run returns the code a program gave to exit instead when it called
+// it.
+const (
+	ExitSuccess = 0
+	// ExitException is returned when a program raised an exception, a test failed or Check found warnings.
+	ExitException = 1
+	// ExitCompileError is returned when a program could not be scanned, parsed, compiled or loaded.
+	ExitCompileError = 2
+	// ExitError is returned for the other errors, such as a file that can not be read or written.
+	ExitError = 3
+)
+
+// Run runs a program on a new interpreter, printing its errors to the standard error set with WithStderr, and returns
+// the exit code of the process. The program is read from filePath when fileContent is empty. Programs built with Build
+// always run on the virtual machine.
+func Run(filePath, fileContent string, opts ...Option) int {
+	if fileContent == "" && filepath.Ext(filePath) == vm.Extension {
+		opts = append(opts, WithBackend(VirtualMachine))
+	}
+	var stderr io.Writer = os.Stderr
+	if o := newOptions(opts); o.stderr != nil {
+		stderr = o.stderr
 	}
+	interpreter, err := New(opts...)
+	if err == nil {
+		if fileContent == "" {
+			err = interpreter.EvalFile(filePath)
+		} else {
+			err = interpreter.eval(filePath, fileContent)
+		}
+	}
+	return report(err, stderr)
+}
+
+// Build compiles a program to bytecode and writes it to outputPath, so that it can be run later without being
+// compiled again. A program built with WithOS has to be run with it as well.
+func Build(filePath, fileContent, outputPath string, opts ...Option) int {
+	interpreter, err := New(append(opts, WithBackend(VirtualMachine))...)
 	if err != nil {
-		fmt.Printf("Read error: %v", err)
-		return 1
+		return report(err, os.Stderr)
+	}
+	rootParseNode, err := parse(filePath, fileContent)
+	if err != nil {
+		return report(err, os.Stderr)
+	}
+	program, err := compiler.CompileProgram(rootParseNode, interpreter.scope)
+	if err != nil {
+		return report(&CompileError{Err: err}, os.Stderr)
+	}
+	data, err := vm.Encode(program)
+	if err == nil {
+		err = os.WriteFile(outputPath, data, 0644)
 	}
-	lexTokens, err := lexer.Lex(stream, filePath)
 	if err != nil {
-		fmt.Printf("Scanning error: %v", err)
-		return 1
+		fmt.Fprintln(os.Stderr, "Build error:", err)
+		return ExitError
 	}
-	rootParseNode, err := parser.Parse(lexTokens)
+	return ExitSuccess
+}
+
+// Lint compiles a program without running it and returns the warnings the passes of package lint find in it. It
+// returns an error instead when the program can not be compiled.
+func Lint(filePath, fileContent string, opts ...Option) ([]*lint.Diagnostic, error) {
+	interpreter, err := New(opts...)
 	if err != nil {
-		fmt.Printf("Parsing error: %v", err)
-		return 1
+		return nil, err
 	}
-	scope := getInitialScope()
-	rootCompileNode, err := compiler.Compile(rootParseNode, scope)
+	rootParseNode, err := parse(filePath, fileContent)
 	if err != nil {
-		fmt.Printf("Compile error: %v", err)
-		return 1
+		return nil, err
+	}
+	if _, _, err := interpreter.compile(rootParseNode); err != nil {
+		return nil, err
 	}
-	res := executer.Execute(rootCompileNode, scope)
-	if res.ReturnType == core.EXCEPTION {
-		fmt.Println(res.Pointer.Variable.VariableInterface.(*core.StackTrace).GetStringValue())
-		return 1
+	return lint.Check(rootParseNode), nil
+}
+
+// Check lints a program and writes the error or the warnings it has to output, one per line or, with asJSON, as a
+// JSON array of lint.Diagnostic. It returns the exit code for the error, ExitException when there are warnings and
+// ExitSuccess otherwise.
+func Check(filePath, fileContent string, output io.Writer, asJSON bool, opts ...Option) int {
+	diagnostics, err := Lint(filePath, fileContent, opts...)
+	if err != nil {
+		diagnostics = []*lint.Diagnostic{errorDiagnostic(filePath, err)}
+	}
+	if asJSON {
+		data, _ := json.MarshalIndent(append([]*lint.Diagnostic{}, diagnostics...), "", "\t")
+		fmt.Fprintln(output, string(data))
+	} else if err != nil {
+		fmt.Fprintln(output, err)
 	} else {
-		return 0
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintln(output, diagnostic)
+		}
+	}
+	if err != nil {
+		return exitCode(err)
 	}
+	if len(diagnostics) > 0 {
+		return ExitException
+	}
+	return ExitSuccess
 }
 
-func getInitialScope() *core.Scope {
-	scope := core.NewScopeWithName("main")
-	scope.AddBlock(builtin.Block)
-	importModule(standard.Module, scope)
-	scope.CreateBlock()
-	return scope
+// errorPosition matches the position errors give for the token they are about.
+var errorPosition = regexp.MustCompile(`(?i)at line (\d+) position (\d+)`)
+
+// errorDiagnostic returns the diagnostic of an error that stops the program at filePath from compiling, it has the
+// position of the first token the error mentions.
+func errorDiagnostic(filePath string, err error) *lint.Diagnostic {
+	diagnostic := &lint.Diagnostic{File: filePath, Severity: lint.SeverityError, Message: err.Error()}
+	if match := errorPosition.FindStringSubmatch(err.Error()); match != nil {
+		diagnostic.Line, _ = strconv.Atoi(match[1])
+		diagnostic.Position, _ = strconv.Atoi(match[2])
+	}
+	return diagnostic
+}
+
+// Lex splits a program into tokens. The program is read from filePath when fileContent is empty.
+func Lex(filePath, fileContent string) ([]*lexer.LexicalToken, error) {
+	return lex(filePath, fileContent)
 }
 
-func importModule(module *module.Module, scope *core.Scope) *core.Return {
+// Parse forms the syntax tree of a program. The program is read from filePath when fileContent is empty.
+func Parse(filePath, fileContent string) (*parser.ParseNode, error) {
+	return parse(filePath, fileContent)
+}
+
+// BuildModule compiles the root file of a module to bytecode, to be stored in its Compiled field.
+func BuildModule(module *module.Module) ([]byte, error) {
+	rootParseNode, err := parse(module.Name, module.RootFile)
+	if err != nil {
+		return nil, err
+	}
+	scope := getBaseScope()
 	scope.AddBlock(module.NativeBlock)
-	stream := reader.ReadString(module.RootFile)
-	lexTokens, err := lexer.Lex(stream, module.Name)
+	scope.CreateBlock()
+	program, err := compiler.CompileProgram(rootParseNode, scope)
+	if err != nil {
+		return nil, err
+	}
+	return vm.Encode(program)
+}
+
+func parse(filePath, fileContent string) (*parser.ParseNode, error) {
+	return parseWith(parser.Parse, filePath, fileContent)
+}
+
+// parseInteractive parses a program whose last statement can be an expression, see parser.ParseInteractive.
+func parseInteractive(filePath, fileContent string) (*parser.ParseNode, error) {
+	return parseWith(parser.ParseInteractive, filePath, fileContent)
+}
+
+// parseWith parses a program with the given parser function. Go panics raised by the parser are recovered and
+// returned as a ParseError, so a malformed program can not crash the host.
+func parseWith(parse func([]*lexer.LexicalToken) (*parser.ParseNode, error), filePath, fileContent string) (rootParseNode *parser.ParseNode, err error) {
+	lexTokens, err := lex(filePath, fileContent)
 	if err != nil {
-		fmt.Printf("Scanning error: %v", err)
-		os.Exit(-1)
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rootParseNode, err = nil, &ParseError{Err: fmt.Errorf("internal error: %v", r)}
+		}
+	}()
+	rootParseNode, err = parse(lexTokens)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	return rootParseNode, nil
+}
+
+func lex(filePath, fileContent string) ([]*lexer.LexicalToken, error) {
+	var stream *bufio.Reader
+	var err error
+	if fileContent == "" {
+		stream, err = reader.ReadFile(filePath)
+	} else {
+		stream = reader.ReadString(fileContent)
 	}
-	rootParseNode, err := parser.Parse(lexTokens)
 	if err != nil {
-		fmt.Printf("Parsing error: %v", err)
-		os.Exit(-1)
+		return nil, &ReadError{Err: err}
 	}
-	rootCompileNode, err := compiler.Compile(rootParseNode, scope)
+	lexTokens, err := lexer.Lex(stream, filePath)
 	if err != nil {
-		fmt.Printf("Compile error: %v", err)
-		os.Exit(-1)
+		return nil, &ScanError{Err: err}
+	}
+	return lexTokens, nil
+}
+
+// report prints the error to output and returns the exit code for it. The exit of a program is not printed, its code
+// is returned as it is.
+func report(err error, output io.Writer) int {
+	var exit *Exit
+	if err == nil || errors.As(err, &exit) {
+		return exitCode(err)
 	}
-	return executer.Execute(rootCompileNode, scope)
+	fmt.Fprintln(output, err)
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	var exit *Exit
+	var runtimeError *RuntimeError
+	var scanError *ScanError
+	var parseError *ParseError
+	var compileError *CompileError
+	var loadError *LoadError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &exit):
+		return exit.Code
+	case errors.As(err, &runtimeError):
+		return ExitException
+	case errors.As(err, &scanError), errors.As(err, &parseError), errors.As(err, &compileError), errors.As(err, &loadError):
+		return ExitCompileError
+	}
+	return ExitError
+}
+
+func getBaseScope() *core.Scope {
+	scope := core.NewScopeWithName("main")
+	scope.AddBlock(builtin.Block)
+	return scope
 }
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TestSuffix ends the names of the files Test runs in a directory.
+const TestSuffix = "_test.selinus"
+
+// Test runs the test programs found at the given paths, each on a new interpreter, and writes their results to
+// output. A path is either a program, which is run whatever its name is, or a directory, whose files ending in
+// TestSuffix are run, including the ones in its subdirectories. A test passes when it runs without an error or exits
+// with 0, the error and the output of a failing test are written after its result. It returns ExitException when a test
+// failed.
+func Test(paths []string, output io.Writer, opts ...Option) int {
+	var files []string
+	for _, path := range paths {
+		found, err := findTests(path)
+		if err != nil {
+			fmt.Fprintln(output, "Read error:", err)
+			return ExitError
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(output, "no test files")
+		return ExitSuccess
+	}
+	code := ExitSuccess
+	for _, file := range files {
+		buffer := &bytes.Buffer{}
+		start := time.Now()
+		interpreter, err := New(append(append([]Option{}, opts...), WithStdout(buffer), WithStderr(buffer))...)
+		if err == nil {
+			err = interpreter.EvalFile(file)
+		}
+		elapsed := time.Since(start).Seconds()
+		if exitCode(err) == ExitSuccess {
+			fmt.Fprintf(output, "ok    %s (%.3fs)\n", file, elapsed)
+			continue
+		}
+		code = ExitException
+		fmt.Fprintf(output, "FAIL  %s (%.3fs)\n", file, elapsed)
+		fmt.Fprintln(output, indent(err.Error()))
+		if buffer.Len() > 0 {
+			fmt.Fprintln(output, indent(strings.TrimSuffix(buffer.String(), "\n")))
+		}
+	}
+	return code
+}
+
+func findTests(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), TestSuffix) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// Closure is a function compiled to bytecode together with the frame it was created in.
+type Closure struct {
+	program   *Program
+	prototype *Prototype
+	frame     *core.Frame
+}
+
+// Execute runs the function on a new machine, the arguments are expected in the first slots of the frame of scope.
+func (c *Closure) Execute(scope *core.Scope) *core.Return {
+	m := &machine{thread: scope.Thread}
+	return m.run(c.program, c.prototype, scope.Frame)
+}
+
+func (c *Closure) GetType() *core.Type {
+	return c.prototype.Typ
+}
+
+func (c *Closure) GetParameters() []*core.Parameter {
+	return c.prototype.Parameters
+}
+
+func (c *Closure) GetReturnType() *core.Type {
+	return c.prototype.ReturnType
+}
+
+func (c *Closure) GetScope() *core.Scope {
+	return &core.Scope{Name: c.prototype.Name, Frame: c.frame}
+}
+
+func (c *Closure) GetFrameSize() int {
+	return c.prototype.FrameSize
+}
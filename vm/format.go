@@ -0,0 +1,567 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+	"hash/crc32"
+	"math/big"
+	"sort"
+)
+
+// A serialized program starts with a header made of the magic bytes, the version of the format and the CRC-32 checksum
+// of the payload. The payload holds the type table followed by the program. Integers are stored as varints and
+// strings are prefixed with their length.
+
+// FormatVersion is the version of the format of serialized programs. It has to be increased whenever the format or
+// the meaning of an instruction changes, Load refuses programs of any other version.
+const FormatVersion = 4
+
+// Extension is the file extension of serialized programs.
+const Extension = ".selc"
+
+var magic = []byte("SELC")
+
+const headerLength = 10
+
+// namedTypes are the types that are shared by all programs, they are serialized by name. Other types are serialized
+// by structure, which only works for generic types since they are compared by their generics.
+var namedTypes = func() map[string]*core.Type {
+	types := make(map[string]*core.Type)
+	for _, typ := range []*core.Type{core.VariableType, core.TypeType, core.SetType, core.StackTraceType, builtin.IntegerType, builtin.BigIntegerType, builtin.BooleanType, builtin.StringType, builtin.FunctionType, builtin.ChannelType} {
+		types[typ.Name] = typ
+	}
+	return types
+}()
+
+const (
+	namedTypeTag byte = iota
+	genericTypeTag
+)
+
+const (
+	nilPointerTag byte = iota
+	referenceTag
+	valueTag
+)
+
+const (
+	noVariableTag byte = iota
+	integerTag
+	bigIntegerTag
+	stringTag
+	booleanTag
+	setTag
+	typeTag
+)
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) int(value int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutVarint(b[:], value)])
+}
+
+func (w *writer) string(value string) {
+	w.int(int64(len(value)))
+	w.WriteString(value)
+}
+
+func (w *writer) bool(value bool) {
+	if value {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+}
+
+type encoder struct {
+	program *Program
+	types   writer
+	body    writer
+	indexes map[*core.Type]int64
+	err     error
+}
+
+// Encode serializes a program so that it can be loaded without being compiled again.
+func Encode(program *Program) ([]byte, error) {
+	e := &encoder{program: program, indexes: make(map[*core.Type]int64)}
+	e.body.int(int64(program.Base))
+	e.body.int(int64(program.Globals))
+	e.body.int(int64(len(program.Names)))
+	for _, name := range program.Names {
+		e.body.string(name)
+	}
+	e.body.int(int64(len(program.Types)))
+	for _, typ := range program.Types {
+		e.body.int(e.typ(typ))
+	}
+	e.body.int(int64(len(program.Constants)))
+	for i, constant := range program.Constants {
+		if name, ok := program.References[int32(i)]; ok {
+			e.body.WriteByte(referenceTag)
+			e.body.string(name)
+			continue
+		}
+		e.pointer(constant)
+	}
+	e.body.int(int64(len(program.Origins)))
+	for _, origin := range program.Origins {
+		e.body.string(origin.Position)
+		e.body.int(int64(origin.Parent))
+	}
+	e.body.int(int64(len(program.Prototypes)))
+	for _, prototype := range program.Prototypes {
+		e.prototype(prototype)
+	}
+	e.prototype(program.Main)
+	names := make([]string, 0, len(program.Exports))
+	for name := range program.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	e.body.int(int64(len(names)))
+	for _, name := range names {
+		declaration := program.Exports[name]
+		e.body.string(name)
+		e.body.int(e.typ(declaration.Typ))
+		e.body.bool(declaration.Immutable)
+		e.body.bool(declaration.Constant)
+		if declaration.Slot == nil {
+			e.body.int(-1)
+		} else {
+			e.body.int(int64(declaration.Slot.Index))
+		}
+		if declaration.Constant {
+			e.variable(declaration.Variable)
+		} else {
+			e.body.WriteByte(noVariableTag)
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	payload := &writer{}
+	payload.int(int64(len(e.indexes)))
+	payload.Write(e.types.Bytes())
+	payload.Write(e.body.Bytes())
+	data := make([]byte, headerLength, headerLength+payload.Len())
+	copy(data, magic)
+	binary.BigEndian.PutUint16(data[4:], FormatVersion)
+	binary.BigEndian.PutUint32(data[6:], crc32.ChecksumIEEE(payload.Bytes()))
+	return append(data, payload.Bytes()...), nil
+}
+
+// typ adds the type to the type table after the types it depends on and returns its index, or -1 for nil.
+func (e *encoder) typ(typ *core.Type) int64 {
+	if typ == nil {
+		return -1
+	}
+	if index, ok := e.indexes[typ]; ok {
+		return index
+	}
+	if namedTypes[typ.Name] == typ {
+		e.types.WriteByte(namedTypeTag)
+		e.types.string(typ.Name)
+	} else if typ.Generic {
+		parent := e.typ(typ.Parent)
+		generics := make([]int64, len(typ.Generics))
+		for i, generic := range typ.Generics {
+			generics[i] = e.typ(generic)
+		}
+		e.types.WriteByte(genericTypeTag)
+		e.types.string(typ.Name)
+		e.types.int(parent)
+		e.types.int(int64(len(generics)))
+		for _, generic := range generics {
+			e.types.int(generic)
+		}
+	} else {
+		e.err = errors.New("type " + typ.Name + " can not be serialized")
+		return -1
+	}
+	index := int64(len(e.indexes))
+	e.indexes[typ] = index
+	return index
+}
+
+func (e *encoder) pointer(pointer *core.Pointer) {
+	if pointer == nil {
+		e.body.WriteByte(nilPointerTag)
+		return
+	}
+	e.body.WriteByte(valueTag)
+	e.body.int(e.typ(pointer.Typ))
+	e.body.bool(pointer.Immutable)
+	e.body.bool(pointer.Constant)
+	e.variable(pointer.Variable)
+}
+
+func (e *encoder) variable(variable *core.Variable) {
+	if variable == nil {
+		e.body.WriteByte(noVariableTag)
+		return
+	}
+	switch value := variable.VariableInterface.(type) {
+	case *builtin.Integer:
+		e.body.WriteByte(integerTag)
+		e.body.int(value.Value)
+	case *builtin.BigInteger:
+		e.body.WriteByte(bigIntegerTag)
+		e.body.string(value.Value.Text(16))
+	case *builtin.String:
+		e.body.WriteByte(stringTag)
+		e.body.string(value.Value)
+	case *builtin.Boolean:
+		e.body.WriteByte(booleanTag)
+		e.body.bool(value.Value)
+	case *core.SetVariable:
+		e.body.WriteByte(setTag)
+		e.body.int(int64(len(value.Children)))
+		for _, child := range value.Children {
+			e.pointer(child)
+		}
+	case *core.TypeVariable:
+		e.body.WriteByte(typeTag)
+		e.body.int(e.typ(value.Value))
+	default:
+		e.err = errors.New("value of type " + variable.GetType().Name + " can not be serialized")
+	}
+}
+
+func (e *encoder) prototype(prototype *Prototype) {
+	e.body.string(prototype.Name)
+	e.body.int(int64(prototype.FrameSize))
+	e.body.int(int64(len(prototype.Parameters)))
+	for _, parameter := range prototype.Parameters {
+		e.body.string(parameter.Name)
+		e.body.int(e.typ(parameter.Typ))
+		e.pointer(parameter.DefaultValue)
+	}
+	e.body.int(e.typ(prototype.Typ))
+	e.body.int(e.typ(prototype.ReturnType))
+	e.body.int(int64(len(prototype.Code)))
+	for _, instruction := range prototype.Code {
+		e.body.WriteByte(byte(instruction.Opcode))
+		e.body.int(int64(instruction.A))
+		e.body.int(int64(instruction.B))
+		e.body.int(int64(instruction.C))
+		e.body.int(int64(instruction.Origin))
+	}
+}
+
+var errTruncated = errors.New("unexpected end of data")
+
+// reader reads the payload of a serialized program. The first error is kept and every read after it returns zero
+// values, so that it only has to be checked at the end.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.data = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.data) == 0 {
+		r.fail(errTruncated)
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *reader) int() int64 {
+	value, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail(errTruncated)
+		return 0
+	}
+	r.data = r.data[n:]
+	return value
+}
+
+// length reads the number of elements of a table, which can not exceed the number of remaining bytes.
+func (r *reader) length() int {
+	length := r.int()
+	if length < 0 || length > int64(len(r.data)) {
+		r.fail(errTruncated)
+		return 0
+	}
+	return int(length)
+}
+
+func (r *reader) string() string {
+	length := r.length()
+	value := string(r.data[:length])
+	r.data = r.data[length:]
+	return value
+}
+
+func (r *reader) bool() bool {
+	return r.byte() != 0
+}
+
+type decoder struct {
+	reader
+	types []*core.Type
+}
+
+func (d *decoder) typ() *core.Type {
+	index := d.int()
+	if index == -1 {
+		return nil
+	}
+	if index < 0 || index >= int64(len(d.types)) {
+		d.fail(fmt.Errorf("invalid type index %d", index))
+		return nil
+	}
+	return d.types[index]
+}
+
+// Load deserializes a program produced by Encode and links it to the scope it is going to run in. The scope has to
+// declare the same names and have the same frame layout as the scope the program was compiled against. Load reserves
+// the global slots of the program and declares its exports in the current block of the scope.
+func Load(data []byte, scope *core.Scope) (*Program, error) {
+	if len(data) < headerLength || !bytes.Equal(data[:4], magic) {
+		return nil, errors.New("not a compiled selinus program")
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d, expected %d", version, FormatVersion)
+	}
+	payload := data[headerLength:]
+	if binary.BigEndian.Uint32(data[6:]) != crc32.ChecksumIEEE(payload) {
+		return nil, errors.New("checksum mismatch, the program is corrupted")
+	}
+	d := &decoder{reader: reader{data: payload}}
+	for i := d.length(); i > 0; i-- {
+		switch d.byte() {
+		case namedTypeTag:
+			name := d.string()
+			typ := namedTypes[name]
+			if typ == nil {
+				d.fail(errors.New("unknown type " + name))
+			}
+			d.types = append(d.types, typ)
+		case genericTypeTag:
+			typ := &core.Type{Name: d.string(), Generic: true}
+			typ.Parent = d.typ()
+			for j := d.length(); j > 0; j-- {
+				typ.Generics = append(typ.Generics, d.typ())
+			}
+			d.types = append(d.types, typ)
+		default:
+			d.fail(errors.New("invalid type"))
+		}
+	}
+	program := &Program{References: make(map[int32]string), Exports: make(map[string]*core.Pointer)}
+	program.Base = int(d.int())
+	program.Globals = int(d.int())
+	for i := d.length(); i > 0; i-- {
+		program.Names = append(program.Names, d.string())
+	}
+	for i := d.length(); i > 0; i-- {
+		program.Types = append(program.Types, d.typ())
+	}
+	for i := d.length(); i > 0; i-- {
+		if len(d.data) > 0 && d.data[0] == referenceTag {
+			d.byte()
+			program.References[int32(len(program.Constants))] = d.string()
+			program.Constants = append(program.Constants, nil)
+			continue
+		}
+		program.Constants = append(program.Constants, d.pointer())
+	}
+	for i := d.length(); i > 0; i-- {
+		program.Origins = append(program.Origins, Origin{Position: d.string(), Parent: int32(d.int())})
+	}
+	for i := d.length(); i > 0; i-- {
+		program.Prototypes = append(program.Prototypes, d.prototype())
+	}
+	program.Main = d.prototype()
+	for i := d.length(); i > 0; i-- {
+		name := d.string()
+		declaration := &core.Pointer{Typ: d.typ(), Immutable: d.bool(), Constant: d.bool()}
+		if index := d.int(); index >= 0 {
+			declaration.Slot = &core.Slot{Level: 0, Index: int(index)}
+		}
+		declaration.Variable = d.variable()
+		program.Exports[name] = declaration
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.fail(errors.New("unexpected data after the program"))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := program.validate(); err != nil {
+		return nil, err
+	}
+	for index, name := range program.References {
+		declaration := scope.MustGet(name)
+		if declaration == nil {
+			return nil, errors.New(name + " is not declared")
+		}
+		program.Constants[index] = declaration
+	}
+	if program.Base != len(scope.Frame.Slots) {
+		return nil, fmt.Errorf("program expects %d global variables but the scope has %d", program.Base, len(scope.Frame.Slots))
+	}
+	for i := 0; i < program.Globals; i++ {
+		scope.Frame.Reserve()
+	}
+	for name, declaration := range program.Exports {
+		scope.DeclareAndSet(name, declaration)
+	}
+	return program, nil
+}
+
+func (d *decoder) pointer() *core.Pointer {
+	switch d.byte() {
+	case nilPointerTag:
+		return nil
+	case valueTag:
+		pointer := &core.Pointer{Typ: d.typ(), Immutable: d.bool(), Constant: d.bool()}
+		pointer.Variable = d.variable()
+		return pointer
+	}
+	d.fail(errors.New("invalid constant"))
+	return nil
+}
+
+func (d *decoder) variable() *core.Variable {
+	switch d.byte() {
+	case noVariableTag:
+		return nil
+	case integerTag:
+		return core.NewVariable(&builtin.Integer{Value: d.int()})
+	case bigIntegerTag:
+		value, ok := new(big.Int).SetString(d.string(), 16)
+		if !ok {
+			d.fail(errors.New("invalid big integer"))
+		}
+		return core.NewVariable(&builtin.BigInteger{Value: value})
+	case stringTag:
+		return core.NewVariable(&builtin.String{Value: d.string()})
+	case booleanTag:
+		return core.NewVariable(&builtin.Boolean{Value: d.bool()})
+	case setTag:
+		var children []*core.Pointer
+		for i := d.length(); i > 0; i-- {
+			children = append(children, d.pointer())
+		}
+		return core.NewVariable(&core.SetVariable{Children: children})
+	case typeTag:
+		return core.TypeToVariable(d.typ())
+	}
+	d.fail(errors.New("invalid value"))
+	return nil
+}
+
+func (d *decoder) prototype() *Prototype {
+	prototype := &Prototype{Name: d.string(), FrameSize: int(d.int())}
+	for i := d.length(); i > 0; i-- {
+		prototype.Parameters = append(prototype.Parameters, &core.Parameter{Name: d.string(), Typ: d.typ(), DefaultValue: d.pointer()})
+	}
+	prototype.Typ = d.typ()
+	prototype.ReturnType = d.typ()
+	for i := d.length(); i > 0; i-- {
+		prototype.Code = append(prototype.Code, Instruction{Opcode: Opcode(d.byte()), A: int32(d.int()), B: int32(d.int()), C: int32(d.int()), Origin: int32(d.int())})
+	}
+	return prototype
+}
+
+// validate checks that every instruction of the program has a known opcode and that its operands index into the tables
+// of the program and the frame of its function, so that a program that was not produced by Encode is rejected by Load
+// instead of crashing the machine.
+func (program *Program) validate() error {
+	for i, origin := range program.Origins {
+		if origin.Parent < -1 || int(origin.Parent) >= len(program.Origins) {
+			return fmt.Errorf("invalid parent of origin %d", i)
+		}
+	}
+	frameSize := program.Base + program.Globals
+	if err := program.validatePrototype(program.Main, frameSize); err != nil {
+		return err
+	}
+	for _, prototype := range program.Prototypes {
+		if err := program.validatePrototype(prototype, prototype.FrameSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (program *Program) validatePrototype(prototype *Prototype, frameSize int) error {
+	in := func(index int32, length int) bool {
+		return index >= 0 && int(index) < length
+	}
+	// Slots that may be negative are not stored, jumps may continue right after the last instruction to return.
+	slot := func(index int32) bool {
+		return index < 0 || int(index) < frameSize
+	}
+	target := func(index int32) bool {
+		return index >= 0 && int(index) <= len(prototype.Code)
+	}
+	for i, instruction := range prototype.Code {
+		valid := instruction.Origin >= -1 && int(instruction.Origin) < len(program.Origins)
+		switch instruction.Opcode {
+		case OpConstant:
+			valid = valid && in(instruction.A, len(program.Constants))
+		case OpLoad, OpLoadAssigned:
+			valid = valid && instruction.A >= 0 && instruction.B >= 0 && in(instruction.C, len(program.Names))
+		case OpLoadFunction:
+			if instruction.A < 0 {
+				valid = valid && in(instruction.B, len(program.Constants))
+			} else {
+				valid = valid && instruction.B >= 0
+			}
+			valid = valid && in(instruction.C, len(program.Names))
+		case OpDeclare:
+			valid = valid && in(instruction.A, frameSize) && in(instruction.B, len(program.Types))
+		case OpConvert:
+			valid = valid && in(instruction.A, len(program.Types))
+		case OpMakeSet:
+			valid = valid && instruction.A >= 0
+		case OpClosure:
+			valid = valid && in(instruction.A, len(program.Prototypes)) && slot(instruction.B)
+		case OpCall, OpTailCall:
+			valid = valid && instruction.A >= 0 && in(instruction.C, len(program.Names))
+		case OpSpawn:
+			valid = valid && instruction.A >= 0 && in(instruction.C, len(program.Names)) && instruction.Origin >= 0
+		case OpJump, OpJumpIfFalse, OpJumpIfTrueOrPop, OpJumpIfFalseOrPop, OpLoopNext:
+			valid = valid && target(instruction.A)
+		case OpLoopStart:
+			valid = valid && slot(instruction.A)
+		case OpChannel:
+			valid = valid && in(instruction.A, len(program.Types))
+		case OpSelect:
+			valid = valid && instruction.A >= 0 && i+int(instruction.A) < len(prototype.Code) &&
+				(instruction.C < 0 || target(instruction.C))
+			for j := i + 1; valid && j <= i+int(instruction.A); j++ {
+				valid = prototype.Code[j].Opcode == OpSelectCase
+			}
+		case OpSelectCase:
+			valid = valid && i > 0 && slot(instruction.A) && instruction.B < int32(len(program.Types)) &&
+				(instruction.B >= 0 || instruction.A < 0) && target(instruction.C)
+		case OpPop, OpSet, OpToBoolean, OpToInteger, OpArithmetic, OpBigIntegerArithmetic, OpConcatenate, OpCompare,
+			OpReturn, OpStep, OpJoin, OpSend, OpReceive, OpClose:
+		default:
+			return fmt.Errorf("unknown opcode %d at instruction %d of %s", instruction.Opcode, i, prototype.Name)
+		}
+		if !valid {
+			return fmt.Errorf("invalid operands at instruction %d of %s", i, prototype.Name)
+		}
+	}
+	return nil
+}
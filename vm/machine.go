@@ -0,0 +1,377 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/cevatbarisyilmaz/selinus/compiler/builtin"
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+// call is a function invocation of the machine. base is the height of the stack when the function was entered.
+type call struct {
+	program   *Program
+	prototype *Prototype
+	pc        int
+	frame     *core.Frame
+	base      int
+}
+
+type machine struct {
+	stack   []*core.Pointer
+	calls   []call
+	current call
+	thread  *core.Thread
+}
+
+// loop is the state of a loop, it is kept on the stack while the loop runs.
+type loop struct {
+	next    int64
+	to      int64
+	counter *core.Pointer
+}
+
+func (*loop) GetType() *core.Type {
+	return core.VariableType
+}
+
+var (
+	trueValue  = builtin.NewBooleanPointer(true)
+	falseValue = builtin.NewBooleanPointer(false)
+)
+
+func booleanValue(value bool) *core.Pointer {
+	if value {
+		return trueValue
+	}
+	return falseValue
+}
+
+// Run executes the top level code of the program in the frame of scope. Go panics are recovered and reported as an
+// exception pointing at the statement that caused them, like executer.Execute does.
+func Run(program *Program, scope *core.Scope) (res *core.Return) {
+	m := &machine{thread: scope.Thread}
+	defer func() {
+		if r := recover(); r != nil {
+			res = core.NewExceptionReturn(fmt.Sprint("internal error: ", r))
+			entry := m.current
+			if len(m.calls) > 0 {
+				entry = m.calls[0]
+			}
+			if entry.pc > 0 {
+				origin := entry.prototype.Code[entry.pc-1].Origin
+				for origin >= 0 && entry.program.Origins[origin].Parent >= 0 {
+					origin = entry.program.Origins[origin].Parent
+				}
+				if origin >= 0 {
+					core.AddPositionToStackTrace(res, entry.program.Origins[origin].Position)
+				}
+			}
+		}
+	}()
+	return m.run(program, program.Main, scope.Frame)
+}
+
+func (m *machine) push(pointer *core.Pointer) {
+	m.stack = append(m.stack, pointer)
+}
+
+func (m *machine) pop() *core.Pointer {
+	pointer := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return pointer
+}
+
+// fail adds the positions of the failing instruction and of the calls that lead to it to the stack trace of the
+// exception.
+func (m *machine) fail(exception *core.Return) *core.Return {
+	m.trace(exception, m.current)
+	for len(m.calls) > 0 {
+		m.current = m.calls[len(m.calls)-1]
+		m.calls = m.calls[:len(m.calls)-1]
+		m.trace(exception, m.current)
+		if m.thread != nil {
+			m.thread.Depth--
+		}
+	}
+	return exception
+}
+
+func (m *machine) trace(exception *core.Return, c call) {
+	for origin := c.prototype.Code[c.pc-1].Origin; origin >= 0; origin = c.program.Origins[origin].Parent {
+		core.AddPositionToStackTrace(exception, c.program.Origins[origin].Position)
+	}
+}
+
+func (m *machine) run(program *Program, prototype *Prototype, frame *core.Frame) *core.Return {
+	m.current = call{program: program, prototype: prototype, frame: frame}
+	for {
+		code := m.current.prototype.Code
+		if m.current.pc == len(code) {
+			return &core.Return{ReturnType: core.NOTHING, Pointer: nil}
+		}
+		instruction := &code[m.current.pc]
+		m.current.pc++
+		var exception *core.Return
+		switch instruction.Opcode {
+		case OpConstant:
+			m.push(m.current.program.Constants[instruction.A])
+		case OpPop:
+			m.stack = m.stack[:len(m.stack)-1]
+		case OpLoad, OpLoadAssigned:
+			pointer := m.current.frame.Get(int(instruction.A), int(instruction.B))
+			if pointer == nil || (instruction.Opcode == OpLoadAssigned && pointer.Variable == nil) {
+				exception = core.NewExceptionReturn("uninitialized variable " + m.current.program.Names[instruction.C])
+				break
+			}
+			m.push(pointer)
+		case OpLoadFunction:
+			var pointer *core.Pointer
+			if instruction.A < 0 {
+				pointer = m.current.program.Constants[instruction.B]
+			} else {
+				pointer = m.current.frame.Get(int(instruction.A), int(instruction.B))
+			}
+			_, exception = core.FunctionOf(pointer, m.current.program.Names[instruction.C])
+			m.push(pointer)
+		case OpDeclare:
+			pointer := &core.Pointer{Typ: m.current.program.Types[instruction.B]}
+			m.current.frame.Slots[instruction.A] = pointer
+			m.push(pointer)
+		case OpSet:
+			target := m.pop()
+			value := m.stack[len(m.stack)-1]
+			if value == nil {
+				exception = core.NewExceptionReturn("right side does not return a variable")
+				break
+			}
+			target.Variable = value.Variable
+		case OpConvert:
+			r := builtin.Convert(m.stack[len(m.stack)-1], m.current.program.Types[instruction.A])
+			if r.ReturnType != core.NOTHING {
+				exception = r
+				break
+			}
+			exception = builtin.Charge(m.thread, r.Pointer, m.stack[len(m.stack)-1])
+			m.stack[len(m.stack)-1] = r.Pointer
+		case OpToBoolean:
+			var value bool
+			value, exception = builtin.ToBoolean(m.stack[len(m.stack)-1])
+			m.stack[len(m.stack)-1] = booleanValue(value)
+		case OpToInteger:
+			r := builtin.Convert(m.stack[len(m.stack)-1], builtin.IntegerType)
+			if r.ReturnType != core.NOTHING {
+				exception = r
+				break
+			}
+			_, exception = builtin.IntegerOf(r.Pointer)
+			m.stack[len(m.stack)-1] = r.Pointer
+		case OpArithmetic:
+			right := m.pop()
+			m.stack[len(m.stack)-1], exception = builtin.Arithmetic(builtin.Operator(instruction.A), m.stack[len(m.stack)-1], right)
+		case OpBigIntegerArithmetic:
+			right := m.pop()
+			m.stack[len(m.stack)-1], exception = builtin.BigIntegerArithmetic(builtin.Operator(instruction.A), m.stack[len(m.stack)-1], right)
+			if exception == nil {
+				exception = builtin.Charge(m.thread, m.stack[len(m.stack)-1], nil)
+			}
+		case OpConcatenate:
+			right := m.pop()
+			m.stack[len(m.stack)-1], exception = builtin.Concatenate(m.stack[len(m.stack)-1], right)
+			if exception == nil {
+				exception = builtin.Charge(m.thread, m.stack[len(m.stack)-1], nil)
+			}
+		case OpCompare:
+			right := m.pop()
+			m.stack[len(m.stack)-1], exception = builtin.Comparison(builtin.Operator(instruction.A), m.stack[len(m.stack)-1], right)
+		case OpMakeSet:
+			start := len(m.stack) - int(instruction.A)
+			children := append([]*core.Pointer(nil), m.stack[start:]...)
+			m.stack = m.stack[:start]
+			set := core.NewSetPointer(children)
+			exception = builtin.Charge(m.thread, set, nil)
+			m.push(set)
+		case OpClosure:
+			prototype := m.current.program.Prototypes[instruction.A]
+			variable := core.NewVariable(&Closure{program: m.current.program, prototype: prototype, frame: m.current.frame})
+			if instruction.B >= 0 {
+				m.current.frame.Slots[instruction.B] = &core.Pointer{Typ: prototype.Typ, Variable: variable, Immutable: true}
+			}
+			m.push(&core.Pointer{Typ: prototype.Typ, Variable: variable})
+		case OpCall, OpTailCall:
+			start := len(m.stack) - int(instruction.A)
+			arguments := m.stack[start:]
+			name := m.current.program.Names[instruction.C]
+			function, _ := m.stack[start-1].Variable.VariableInterface.(core.Function)
+			closure, _ := function.(*Closure)
+			tail := instruction.Opcode == OpTailCall
+			if tail || closure != nil {
+				if !tail && m.thread != nil && m.thread.Depth >= m.thread.MaxDepth {
+					exception = core.NewExceptionReturn(fmt.Sprintf("stack overflow, call depth limit of %d exceeded", m.thread.MaxDepth))
+					break
+				}
+				if exception = m.thread.Step(); exception != nil {
+					break
+				}
+			}
+			if closure != nil {
+				frame := core.NewFrame(closure.prototype.FrameSize, closure.frame)
+				exception = core.BindArguments(frame.Slots, closure.prototype.Parameters, arguments, name)
+				if exception != nil {
+					break
+				}
+				if tail {
+					m.stack = m.stack[:m.current.base]
+					m.current = call{program: closure.program, prototype: closure.prototype, frame: frame, base: m.current.base}
+					break
+				}
+				if m.thread != nil {
+					m.thread.Depth++
+				}
+				m.stack = m.stack[:start-1]
+				m.calls = append(m.calls, m.current)
+				m.current = call{program: closure.program, prototype: closure.prototype, frame: frame, base: len(m.stack)}
+				break
+			}
+			var res *core.Return
+			if tail {
+				res = core.Invoke(m.thread, function, arguments, name)
+			} else {
+				res = core.Call(m.thread, function, arguments, name)
+			}
+			m.stack = m.stack[:start-1]
+			if res.ReturnType == core.EXCEPTION {
+				exception = res
+				break
+			}
+			m.push(res.Pointer)
+		case OpReturn:
+			value := m.pop()
+			if len(m.calls) == 0 {
+				return &core.Return{ReturnType: core.NOTHING, Pointer: value}
+			}
+			m.stack = m.stack[:m.current.base]
+			m.current = m.calls[len(m.calls)-1]
+			m.calls = m.calls[:len(m.calls)-1]
+			if m.thread != nil {
+				m.thread.Depth--
+			}
+			m.push(value)
+		case OpJump:
+			m.current.pc = int(instruction.A)
+		case OpJumpIfFalse:
+			var value bool
+			value, exception = builtin.BooleanOf(m.pop())
+			if !value {
+				m.current.pc = int(instruction.A)
+			}
+		case OpJumpIfTrueOrPop:
+			if m.stack[len(m.stack)-1] == trueValue {
+				m.current.pc = int(instruction.A)
+			} else {
+				m.stack = m.stack[:len(m.stack)-1]
+			}
+		case OpJumpIfFalseOrPop:
+			if m.stack[len(m.stack)-1] == falseValue {
+				m.current.pc = int(instruction.A)
+			} else {
+				m.stack = m.stack[:len(m.stack)-1]
+			}
+		case OpLoopStart:
+			to, _ := builtin.IntegerOf(m.pop())
+			from, _ := builtin.IntegerOf(m.pop())
+			state := &loop{next: from, to: to}
+			if instruction.A >= 0 {
+				state.counter = &core.Pointer{Typ: builtin.IntegerType}
+				m.current.frame.Slots[instruction.A] = state.counter
+			}
+			m.push(&core.Pointer{Typ: core.VariableType, Variable: core.NewVariable(state)})
+		case OpLoopNext:
+			state := m.stack[len(m.stack)-1].Variable.VariableInterface.(*loop)
+			if state.next > state.to {
+				m.stack = m.stack[:len(m.stack)-1]
+				m.current.pc = int(instruction.A)
+				break
+			}
+			if exception = m.thread.Step(); exception != nil {
+				break
+			}
+			if state.counter != nil {
+				state.counter.Variable = core.NewVariable(&builtin.Integer{Value: state.next})
+			}
+			state.next++
+		case OpStep:
+			exception = m.thread.Step()
+		case OpSpawn:
+			start := len(m.stack) - int(instruction.A)
+			arguments := append([]*core.Pointer(nil), m.stack[start:]...)
+			function, _ := m.stack[start-1].Variable.VariableInterface.(core.Function)
+			m.stack = m.stack[:start-1]
+			core.Spawn(m.thread, function, arguments, m.current.program.Names[instruction.C], m.current.program.Origins[instruction.Origin].Position)
+		case OpJoin:
+			exception = m.thread.Join()
+		case OpChannel:
+			var size int64
+			if instruction.B != 0 {
+				size, exception = builtin.IntegerOf(m.pop())
+				if exception != nil {
+					break
+				}
+			}
+			var channel *core.Pointer
+			channel, exception = builtin.NewChannelPointer(m.current.program.Types[instruction.A], size)
+			if exception == nil {
+				exception = builtin.Charge(m.thread, channel, nil)
+			}
+			m.push(channel)
+		case OpSend:
+			value := m.pop()
+			exception = builtin.Send(m.thread, m.stack[len(m.stack)-1], value)
+			m.stack[len(m.stack)-1] = nil
+		case OpReceive:
+			m.stack[len(m.stack)-1], exception = builtin.Receive(m.thread, m.stack[len(m.stack)-1])
+		case OpClose:
+			exception = builtin.Close(m.thread, m.stack[len(m.stack)-1])
+			m.stack[len(m.stack)-1] = nil
+		case OpSelect:
+			descriptors := code[m.current.pc : m.current.pc+int(instruction.A)]
+			m.current.pc += len(descriptors)
+			operands := 0
+			for _, descriptor := range descriptors {
+				operands++
+				if descriptor.B < 0 {
+					operands++
+				}
+			}
+			start := len(m.stack) - operands
+			cases := make([]builtin.SelectCase, len(descriptors))
+			for i, position := 0, start; i < len(descriptors); i++ {
+				cases[i].Channel = m.stack[position]
+				position++
+				if descriptors[i].B < 0 {
+					cases[i].Value = m.stack[position]
+					position++
+				}
+			}
+			m.stack = m.stack[:start]
+			var chosen int
+			var received *core.Pointer
+			chosen, received, exception = builtin.Select(m.thread, cases, instruction.C >= 0)
+			if exception != nil {
+				break
+			}
+			if chosen < 0 {
+				m.current.pc = int(instruction.C)
+				break
+			}
+			descriptor := descriptors[chosen]
+			if descriptor.A >= 0 {
+				m.current.frame.Slots[descriptor.A] = &core.Pointer{Typ: m.current.program.Types[descriptor.B], Variable: received.Variable}
+			}
+			m.current.pc = int(descriptor.C)
+		default:
+			panic(fmt.Sprint("unknown opcode ", instruction.Opcode))
+		}
+		if exception != nil {
+			return m.fail(exception)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"github.com/cevatbarisyilmaz/selinus/compiler/core"
+)
+
+type Opcode byte
+
+// Every instruction pops its operands from the stack and pushes at most one result. A, B and C are the immediate
+// operands of the instruction, their meaning is given for every opcode.
+const (
+	// OpConstant pushes constant A.
+	OpConstant Opcode = iota
+	// OpPop discards the top of the stack.
+	OpPop
+	// OpLoad pushes the variable in slot B of the frame A levels above the current one. C names the variable.
+	OpLoad
+	// OpLoadAssigned is OpLoad for variables that can not be proven to be assigned at compile time.
+	OpLoadAssigned
+	// OpLoadFunction pushes the function in slot B of the frame A levels above the current one, or constant B when A is
+	// negative. C names the function.
+	OpLoadFunction
+	// OpDeclare creates variable of type B in slot A of the current frame and pushes it.
+	OpDeclare
+	// OpSet pops a value and a variable, assigns the value to the variable and pushes the value.
+	OpSet
+	// OpConvert converts the top of the stack to type A.
+	OpConvert
+	// OpToBoolean converts the top of the stack to a boolean.
+	OpToBoolean
+	// OpToInteger converts the top of the stack to an integer.
+	OpToInteger
+	// OpArithmetic applies the integer builtin.Operator A to the two values on top of the stack.
+	OpArithmetic
+	// OpBigIntegerArithmetic applies the big integer builtin.Operator A to the two values on top of the stack.
+	OpBigIntegerArithmetic
+	// OpConcatenate joins the two strings on top of the stack.
+	OpConcatenate
+	// OpCompare applies the comparison builtin.Operator A to the two values on top of the stack.
+	OpCompare
+	// OpMakeSet pops A values and pushes a set of them.
+	OpMakeSet
+	// OpClosure pushes a function created from prototype A that captures the current frame and stores it in slot B when
+	// B is not negative.
+	OpClosure
+	// OpCall pops A arguments and calls the function below them. C names the function.
+	OpCall
+	// OpReturn returns the top of the stack to the caller.
+	OpReturn
+	// OpJump continues with instruction A.
+	OpJump
+	// OpJumpIfFalse pops a boolean and continues with instruction A when it is false.
+	OpJumpIfFalse
+	// OpJumpIfTrueOrPop continues with instruction A when the boolean on top of the stack is true and pops it otherwise.
+	OpJumpIfTrueOrPop
+	// OpJumpIfFalseOrPop continues with instruction A when the boolean on top of the stack is false and pops it
+	// otherwise.
+	OpJumpIfFalseOrPop
+	// OpLoopStart pops the upper and lower bounds of a loop and pushes its state. The counter of the loop is stored in a
+	// new variable in slot A when A is not negative.
+	OpLoopStart
+	// OpLoopNext advances the loop whose state is on top of the stack and counts a step, or pops the state and continues
+	// with instruction A when the loop is over.
+	OpLoopNext
+	// OpTailCall is OpCall for a call in tail position, a function compiled to bytecode replaces the current call
+	// instead of being called from it. It is followed by an OpReturn for the other functions.
+	OpTailCall
+	// OpStep counts an iteration of a loop, see core.Thread.Step.
+	OpStep
+	// OpSpawn pops A arguments and calls the function below them in a new task, see core.Thread.Spawn. C names the
+	// function.
+	OpSpawn
+	// OpJoin waits for the tasks spawned so far, see core.Thread.Join.
+	OpJoin
+	// OpChannel creates a channel of values of type A and pushes it. The size of its buffer is popped when B is not
+	// zero.
+	OpChannel
+	// OpSend pops a value and sends it on the channel below it, which is replaced with nil.
+	OpSend
+	// OpReceive replaces the channel on top of the stack with a value received from it.
+	OpReceive
+	// OpClose replaces the channel on top of the stack with nil after closing it.
+	OpClose
+	// OpSelect performs one of the A cases described by the OpSelectCase instructions that follow it, whose channels
+	// and sent values are popped in order. It continues with instruction C when it has an else case, C is not negative,
+	// and none of the cases can proceed.
+	OpSelect
+	// OpSelectCase describes a case of the preceding OpSelect. B is the type of the received value or negative for a
+	// send, the received value is stored in a new variable in slot A when A is not negative. The select continues with
+	// instruction C when it performs the case.
+	OpSelectCase
+)
+
+type Instruction struct {
+	Opcode Opcode
+	A      int32
+	B      int32
+	C      int32
+	// Origin is the index of the origin of the instruction in Program.Origins.
+	Origin int32
+}
+
+// Origin is the position of the node an instruction was compiled from. Parent is the index of the origin of the
+// enclosing node, or -1 for a statement at the top of a function. Exceptions collect the positions of the whole chain,
+// as the nodes of the tree walker do.
+type Origin struct {
+	Position string
+	Parent   int32
+}
+
+// Prototype is the compiled code of a function.
+type Prototype struct {
+	Name       string
+	Code       []Instruction
+	FrameSize  int
+	Parameters []*core.Parameter
+	Typ        *core.Type
+	ReturnType *core.Type
+}
+
+type Program struct {
+	Constants  []*core.Pointer
+	Types      []*core.Type
+	Names      []string
+	Origins    []Origin
+	Prototypes []*Prototype
+	// Main is the code at the top level of the program, it runs in the frame of the scope the program was compiled
+	// against.
+	Main *Prototype
+	// References maps constants that are declarations of the scope the program was compiled against, such as native
+	// functions, to their names. They are looked up again when a serialized program is loaded.
+	References map[int32]string
+	// Base is the size of the frame of the scope the program was compiled against and Globals is the number of slots
+	// the program added to it.
+	Base    int
+	Globals int
+	// Exports are the declarations at the top level of the program, so that other programs can be compiled against it.
+	Exports map[string]*core.Pointer
+}